@@ -8,6 +8,7 @@ require (
 	github.com/joelschutz/stagehand v1.0.0
 	github.com/lafriks/go-tiled v0.12.0
 	github.com/solarlune/ldtkgo v0.9.3
+	github.com/tidwall/gjson v1.6.4
 	golang.org/x/exp v0.0.0-20230817173708-d852ddb80c63
 )
 
@@ -16,7 +17,6 @@ require (
 	github.com/ebitengine/purego v0.4.0 // indirect
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20221017161538-93cebf72946b // indirect
 	github.com/jezek/xgb v1.1.0 // indirect
-	github.com/tidwall/gjson v1.6.4 // indirect
 	github.com/tidwall/match v1.0.1 // indirect
 	github.com/tidwall/pretty v1.0.2 // indirect
 	golang.org/x/exp/shiny v0.0.0-20230817173708-d852ddb80c63 // indirect
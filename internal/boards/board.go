@@ -0,0 +1,69 @@
+package boards
+
+import (
+	"image/color"
+	"image/draw"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// Board is a grid simulation drawn one pixel per cell. It doesn't depend
+// on ebiten, so boards can be stepped and rendered offscreen.
+type Board[V any] interface {
+	Update() error
+	Draw(screen draw.Image)
+	Size() (int, int)
+	Reset() error
+	Setup(m [][]V)
+	GetState() [][]V
+	SetState(m [][]V)
+	Click(btn Button)
+	Hover(x, y int)
+}
+
+// Simulation is a Board running a rule over the materials of a level. The
+// first component of its cells is the quantity it simulates, which scenes
+// compare and record.
+type Simulation interface {
+	Board[mgl32.Vec2]
+	// Load sets the board up from the materials of a level
+	Load(soil [][]levels.Material)
+	// Paint replaces the cell at x, y with a fresh one of material m. The
+	// change survives Reset.
+	Paint(x, y int, m levels.Material)
+	// Materials returns the materials of the level with the painted cells
+	Materials() [][]levels.Material
+	// Generation changes whenever Materials does, by loading, painting or
+	// the rule itself
+	Generation() uint
+}
+
+// Flow is implemented by simulations that track how their quantity moves
+// between cells.
+type Flow interface {
+	FlowAt(x, y int) mgl32.Vec2
+}
+
+// Thermal is implemented by simulations that track the soil temperature.
+type Thermal interface {
+	DrawTemperature(screen draw.Image)
+}
+
+// SoluteCarrier is implemented by simulations whose water carries a
+// dissolved solute, like nutrients, fed by the sources of a level.
+type SoluteCarrier interface {
+	LoadSources(entities []levels.Entity) error
+	DrawSolute(screen draw.Image)
+}
+
+var (
+	_ Simulation         = (*HumidityBoard)(nil)
+	_ Flow               = (*HumidityBoard)(nil)
+	_ Board[color.Color] = (*EnumBoard)(nil)
+	_ Simulation         = (*ThermalBoard)(nil)
+	_ Thermal            = (*ThermalBoard)(nil)
+	_ Simulation         = (*SoluteBoard)(nil)
+	_ SoluteCarrier      = (*SoluteBoard)(nil)
+	_ Simulation         = (*ErosionBoard)(nil)
+)
@@ -0,0 +1,193 @@
+package boards
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// BoundaryMode decides what lies beyond an edge of the board.
+type BoundaryMode int
+
+const (
+	// Closed edges are dry and impermeable, no water crosses them
+	Closed BoundaryMode = iota
+	// Open edges absorb water, as if a dry permeable cell was past them
+	Open
+	// Periodic edges wrap around to the opposite side
+	Periodic
+	// Fixed edges hold a constant humidity, like a water table
+	Fixed
+	// Mirror edges reflect the cell on the edge back to itself
+	Mirror
+)
+
+var boundaryNames = map[string]BoundaryMode{
+	"closed":   Closed,
+	"open":     Open,
+	"periodic": Periodic,
+	"fixed":    Fixed,
+	"mirror":   Mirror,
+}
+
+// Boundary is the condition of one edge. Value is the humidity of Fixed
+// edges.
+type Boundary struct {
+	Mode  BoundaryMode
+	Value float32
+}
+
+// Edge indexes HumidityBoard.Edges.
+type Edge int
+
+const (
+	LeftEdge Edge = iota
+	RightEdge
+	TopEdge
+	BottomEdge
+)
+
+var edgeNames = map[string]Edge{
+	"left":   LeftEdge,
+	"right":  RightEdge,
+	"top":    TopEdge,
+	"bottom": BottomEdge,
+}
+
+// ParseBoundaries reads a comma separated list of edge=mode pairs, like
+// "left=periodic,right=periodic,bottom=fixed:800". A mode without an edge
+// applies to all of them, later entries override earlier ones. Edges not
+// listed are closed.
+func ParseBoundaries(s string) ([4]Boundary, error) {
+	edges := [4]Boundary{}
+	if strings.TrimSpace(s) == "" {
+		return edges, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		name, mode, found := strings.Cut(item, "=")
+		if !found {
+			name, mode = "", item
+		}
+
+		b := Boundary{}
+		mode, value, hasValue := strings.Cut(mode, ":")
+		m, ok := boundaryNames[strings.ToLower(mode)]
+		if !ok {
+			return edges, fmt.Errorf("unknown boundary %q", mode)
+		}
+		b.Mode = m
+		if m == Fixed {
+			if !hasValue {
+				return edges, fmt.Errorf("boundary %q needs a humidity, like fixed:800", item)
+			}
+			v, err := strconv.ParseFloat(value, 32)
+			if err != nil || v < 0 || v > 1023 {
+				return edges, fmt.Errorf("boundary %q: humidity must be within [0, 1023]", item)
+			}
+			b.Value = float32(v)
+		} else if hasValue {
+			return edges, fmt.Errorf("boundary %q takes no value", item)
+		}
+
+		if name == "" {
+			edges = [4]Boundary{b, b, b, b}
+			continue
+		}
+		e, ok := edgeNames[strings.ToLower(name)]
+		if !ok {
+			return edges, fmt.Errorf("unknown edge %q", name)
+		}
+		edges[e] = b
+	}
+	return edges, nil
+}
+
+// edgeOf returns the edge a cell falls past, false for cells of the board.
+// Cells past a corner follow the horizontal edge first.
+func (ba *HumidityBoard) edgeOf(x, y int) (Edge, bool) {
+	w, h := ba.Size()
+	switch {
+	case x < 0:
+		return LeftEdge, true
+	case x >= w:
+		return RightEdge, true
+	case y < 0:
+		return TopEdge, true
+	case y >= h:
+		return BottomEdge, true
+	}
+	return 0, false
+}
+
+// inside maps a cell past a Periodic or Mirror edge to the cell of the
+// board it wraps or reflects to. It reports false for the other edges.
+func (ba *HumidityBoard) inside(e Edge, x, y int) (int, int, bool) {
+	w, h := ba.Size()
+	switch ba.Edges[e].Mode {
+	case Periodic:
+		return (x + w) % w, (y + h) % h, true
+	case Mirror:
+		return clamp(x, 0, w-1), clamp(y, 0, h-1), true
+	}
+	return x, y, false
+}
+
+// neighbour returns the cell at x, y, applying the boundary of the edge it
+// falls past.
+func (ba *HumidityBoard) neighbour(x, y int) mgl32.Vec2 {
+	e, past := ba.edgeOf(x, y)
+	if !past {
+		return ba.values[x][y]
+	}
+	if ba.Outside != nil {
+		if v, ok := ba.Outside(x, y); ok {
+			return v
+		}
+	}
+	if x, y, ok := ba.inside(e, x, y); ok {
+		return ba.values[x][y]
+	}
+
+	switch b := ba.Edges[e]; b.Mode {
+	case Open:
+		return mgl32.Vec2{0, 1}
+	case Fixed:
+		return mgl32.Vec2{b.Value, 1}
+	}
+	return mgl32.Vec2{0, math.MaxFloat32}
+}
+
+// limits returns the [capacity, field capacity] of a cell, past the edges
+// by the same boundaries as neighbour. Boards set up without materials,
+// and the Open, Fixed and Closed edges, take up to 1023 and hold nothing.
+func (ba *HumidityBoard) limits(x, y int) mgl32.Vec2 {
+	if e, past := ba.edgeOf(x, y); past {
+		if ba.OutsideLimits != nil {
+			if c, ok := ba.OutsideLimits(x, y); ok {
+				return c
+			}
+		}
+		var ok bool
+		if x, y, ok = ba.inside(e, x, y); !ok {
+			return mgl32.Vec2{1023, 0}
+		}
+	}
+	if ba.holding == nil {
+		return mgl32.Vec2{1023, 0}
+	}
+	return ba.holding[x][y]
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,114 @@
+package boards
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+func TestParseBoundaries(t *testing.T) {
+	tests := []struct {
+		in   string
+		want [4]Boundary
+		err  bool
+	}{
+		{in: "", want: [4]Boundary{}},
+		{in: "open", want: [4]Boundary{{Mode: Open}, {Mode: Open}, {Mode: Open}, {Mode: Open}}},
+		{
+			in:   "left=periodic, right=periodic,bottom=fixed:800",
+			want: [4]Boundary{{Mode: Periodic}, {Mode: Periodic}, {}, {Mode: Fixed, Value: 800}},
+		},
+		{in: "mirror,Top=Closed", want: [4]Boundary{{Mode: Mirror}, {Mode: Mirror}, {}, {Mode: Mirror}}},
+		{in: "fixed", err: true},
+		{in: "bottom=fixed:2000", err: true},
+		{in: "open:3", err: true},
+		{in: "middle=open", err: true},
+		{in: "leaky", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBoundaries(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// A single row of air, half wet, stepped once with the same boundary on
+// both sides.
+func stepRow(b Boundary) []float32 {
+	hum := util.MakeMatrixWH(4, 1, mgl32.Vec2{0, 1})
+	hum[0][0][0], hum[1][0][0] = 500, 500
+	ba := &HumidityBoard{
+		Rocks: util.MakeMatrixWH(4, 1, false),
+		Rain:  util.MakeMatrixWH(4, 1, false),
+		Edges: [4]Boundary{b, b, {}, {}},
+	}
+	ba.Setup(hum)
+	ba.Update()
+	row := []float32{}
+	for _, col := range ba.GetState() {
+		row = append(row, col[0][0])
+	}
+	return row
+}
+
+func TestBoundaryModes(t *testing.T) {
+	closed := stepRow(Boundary{})
+	if open := stepRow(Boundary{Mode: Open}); open[0] >= closed[0] {
+		t.Errorf("open edge should drain the first cell: open %v, closed %v", open, closed)
+	}
+	if fixed := stepRow(Boundary{Mode: Fixed, Value: 1023}); fixed[3] <= closed[3] {
+		t.Errorf("fixed edge should wet the last cell: fixed %v, closed %v", fixed, closed)
+	}
+	if wrap := stepRow(Boundary{Mode: Periodic}); wrap[3] <= closed[3] {
+		t.Errorf("periodic edge should wet the last cell from the first: periodic %v, closed %v", wrap, closed)
+	}
+	// Mirroring keeps the water in the board, just like a closed edge
+	if mirror := stepRow(Boundary{Mode: Mirror}); mirror[3] != closed[3] {
+		t.Errorf("mirror edge should not wet the last cell: mirror %v, closed %v", mirror, closed)
+	}
+}
+
+func TestLimitsFollowBoundaries(t *testing.T) {
+	ba := &HumidityBoard{}
+	ba.Load([][]levels.Material{{levels.Sand}, {levels.Clay}})
+	sand, clay := ba.soilHolding(levels.Sand), ba.soilHolding(levels.Clay)
+	open := mgl32.Vec2{1023, 0}
+	tests := []struct {
+		mode        BoundaryMode
+		left, right mgl32.Vec2
+	}{
+		{Closed, open, open},
+		{Open, open, open},
+		{Fixed, open, open},
+		{Periodic, clay, sand},
+		{Mirror, sand, clay},
+	}
+	for _, tt := range tests {
+		ba.Edges = [4]Boundary{{Mode: tt.mode}, {Mode: tt.mode}, {}, {}}
+		if c := ba.limits(-1, 0); c != tt.left {
+			t.Errorf("mode %d: left of the board holds %v, want %v", tt.mode, c, tt.left)
+		}
+		if c := ba.limits(2, 0); c != tt.right {
+			t.Errorf("mode %d: right of the board holds %v, want %v", tt.mode, c, tt.right)
+		}
+	}
+
+	ba.OutsideLimits = func(x, y int) (mgl32.Vec2, bool) { return mgl32.Vec2{7, 3}, x < 0 }
+	if c := ba.limits(-1, 0); c != (mgl32.Vec2{7, 3}) {
+		t.Errorf("left of the board holds %v, want the outside cell", c)
+	}
+	if c := ba.limits(2, 0); c != clay {
+		t.Errorf("right of the board holds %v, want the mirrored clay", c)
+	}
+}
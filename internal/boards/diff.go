@@ -0,0 +1,39 @@
+package boards
+
+import (
+	"image/color"
+	"image/draw"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// DrawDifference colours each cell by the humidity of a minus the one of
+// b: red where a is wetter, blue where b is and white where they match.
+// Only the cells both grids share are drawn.
+func DrawDifference(dst draw.Image, a, b [][]mgl32.Vec2) {
+	for x := 0; x < len(a) && x < len(b); x++ {
+		for y := 0; y < len(a[x]) && y < len(b[x]); y++ {
+			d := a[x][y][0] - b[x][y][0]
+			fade := uint8(255 - 255*min32(abs32(d)/1023, 1))
+			if d >= 0 {
+				dst.Set(x, y, color.RGBA{255, fade, fade, 255})
+			} else {
+				dst.Set(x, y, color.RGBA{fade, fade, 255, 255})
+			}
+		}
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
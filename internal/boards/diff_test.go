@@ -0,0 +1,32 @@
+package boards
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+func TestDrawDifference(t *testing.T) {
+	a := [][]mgl32.Vec2{{{1023, 1}, {0, 1}, {500, 1}}}
+	b := [][]mgl32.Vec2{{{0, 1}, {1023, 1}}}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 3))
+	DrawDifference(img, a, b)
+
+	want := []color.RGBA{
+		{255, 0, 0, 255}, // a wetter
+		{0, 0, 255, 255}, // b wetter
+		{},               // outside b, left untouched
+	}
+	for y, clr := range want {
+		if got := img.RGBAAt(0, y); got != clr {
+			t.Errorf("cell (0, %d) is %v, want %v", y, got, clr)
+		}
+	}
+
+	DrawDifference(img, a, a)
+	if got := img.RGBAAt(0, 0); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("equal cells should be white, got %v", got)
+	}
+}
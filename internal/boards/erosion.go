@@ -0,0 +1,221 @@
+package boards
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+// Transition changes the material of a cell once its saturation, the share
+// of its capacity filled with water, has stayed past Threshold for Ticks
+// steps in a row. The cell becomes To, or with Wash it trades places with
+// the air cell below it. Dry transitions only count once the cell has been
+// wetter than Threshold, so terrain that starts dry stays as it is.
+type Transition struct {
+	Threshold float32
+	Ticks     int
+	To        levels.Material
+	Wash      bool
+}
+
+// Erosion holds the transitions of one material: Wet fires above its
+// threshold and Dry below it. Either may be nil.
+type Erosion struct {
+	Wet, Dry *Transition
+}
+
+// ErosionRules maps each material to its transitions.
+type ErosionRules map[levels.Material]Erosion
+
+// DefaultErosionRules turns soaked loose soil into mud and back once dry,
+// washes soaked sand down through air and hardens dry clay, then dry hard
+// soil into rock.
+func DefaultErosionRules() ErosionRules {
+	return ErosionRules{
+		levels.LooseSoil: {Wet: &Transition{Threshold: 0.9, Ticks: 300, To: levels.Mud}},
+		levels.Mud:       {Dry: &Transition{Threshold: 0.3, Ticks: 600, To: levels.LooseSoil}},
+		levels.Sand:      {Wet: &Transition{Threshold: 0.8, Ticks: 100, Wash: true}},
+		levels.Clay:      {Dry: &Transition{Threshold: 0.05, Ticks: 600, To: levels.HardSoil}},
+		levels.HardSoil:  {Dry: &Transition{Threshold: 0.01, Ticks: 3000, To: levels.Rock}},
+	}
+}
+
+type transitionJSON struct {
+	Threshold float32 `json:"threshold"`
+	Ticks     int     `json:"ticks"`
+	To        string  `json:"to,omitempty"`
+	Wash      bool    `json:"wash,omitempty"`
+}
+
+// ParseErosionRules reads rules from JSON keyed by the material names of the
+// LDtk project, like
+//
+//	{"clay": {"dry": {"threshold": 0.05, "ticks": 600, "to": "hardSoil"}}}
+//
+// Materials missing keep the transitions of base.
+func ParseErosionRules(r io.Reader, base ErosionRules) (ErosionRules, error) {
+	raw := map[string]map[string]transitionJSON{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("erosion rules: %w", err)
+	}
+	rules := ErosionRules{}
+	for m, e := range base {
+		rules[m] = e
+	}
+	for name, kinds := range raw {
+		m, ok := levels.MaterialByName(name)
+		if !ok {
+			return nil, fmt.Errorf("erosion rules: unknown material %q", name)
+		}
+		e := Erosion{}
+		for kind, tj := range kinds {
+			t := &Transition{Threshold: tj.Threshold, Ticks: tj.Ticks, Wash: tj.Wash}
+			if !tj.Wash {
+				if t.To, ok = levels.MaterialByName(tj.To); !ok {
+					return nil, fmt.Errorf("erosion rules: %s %s: unknown material %q", name, kind, tj.To)
+				}
+			}
+			switch kind {
+			case "wet":
+				e.Wet = t
+			case "dry":
+				e.Dry = t
+			default:
+				return nil, fmt.Errorf("erosion rules: %s: unknown transition %q, expected wet or dry", name, kind)
+			}
+		}
+		rules[m] = e
+	}
+	return rules, nil
+}
+
+// ErosionBoard runs the humidity rule on a terrain changed by the water, by
+// its Rules. Reset brings back the loaded materials, with the painted ones.
+//
+// It must be set up with Load.
+type ErosionBoard struct {
+	HumidityBoard
+	Rules ErosionRules
+	// base is the terrain Reset goes back to
+	base [][]levels.Material
+	// Steps each cell has been past its thresholds, dry is -1 until the
+	// cell gets wet
+	wet, dry [][]int
+}
+
+func NewErosionBoard(edges [4]Boundary, rules ErosionRules) *ErosionBoard {
+	return &ErosionBoard{HumidityBoard: HumidityBoard{Edges: edges}, Rules: rules}
+}
+
+func (ba *ErosionBoard) Load(soil [][]levels.Material) {
+	ba.base = make([][]levels.Material, len(soil))
+	for x, row := range soil {
+		ba.base[x] = append([]levels.Material{}, row...)
+	}
+	ba.Reset()
+}
+
+func (ba *ErosionBoard) Reset() error {
+	ba.HumidityBoard.Load(ba.base)
+	w, h := ba.Size()
+	ba.wet = util.MakeMatrixWH(w, h, 0)
+	ba.dry = util.MakeMatrixWH(w, h, -1)
+	return nil
+}
+
+func (ba *ErosionBoard) Paint(x, y int, m levels.Material) {
+	if w, h := ba.Size(); x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+	ba.HumidityBoard.Paint(x, y, m)
+	ba.base[x][y] = m
+	ba.wet[x][y], ba.dry[x][y] = 0, -1
+}
+
+// Click is redeclared so that painting goes through ErosionBoard.Paint.
+func (ba *ErosionBoard) Click(btn Button) {
+	paintClick(ba, ba.hvrX, ba.hvrY, ba.Rocks, btn)
+}
+
+func (ba *ErosionBoard) Update() error {
+	if err := ba.HumidityBoard.Update(); err != nil {
+		return err
+	}
+	// Bottom up, so washed sand moves one cell per step
+	w, h := ba.Size()
+	for y := h - 1; y >= 0; y-- {
+		for x := 0; x < w; x++ {
+			ba.erode(x, y)
+		}
+	}
+	return nil
+}
+
+// erode counts how long a cell has been wet or dry and applies its
+// transition when due.
+func (ba *ErosionBoard) erode(x, y int) {
+	e := ba.Rules[ba.soil[x][y]]
+	sat := float32(0)
+	if c := ba.limits(x, y)[0]; c > 0 {
+		sat = ba.values[x][y][0] / c
+	}
+
+	var due *Transition
+	if t := e.Wet; t != nil && sat >= t.Threshold {
+		if ba.wet[x][y]++; ba.wet[x][y] >= t.Ticks {
+			due = t
+		}
+	} else {
+		ba.wet[x][y] = 0
+	}
+	if t := e.Dry; t != nil && sat <= t.Threshold {
+		if ba.dry[x][y] >= 0 {
+			if ba.dry[x][y]++; ba.dry[x][y] >= t.Ticks {
+				due = t
+			}
+		}
+	} else {
+		ba.dry[x][y] = 0
+	}
+
+	switch {
+	case due == nil:
+	case due.Wash:
+		if _, h := ba.Size(); y+1 < h {
+			if below := ba.soil[x][y+1]; below == levels.Air || below == levels.Empty {
+				ba.swap(x, y, x, y+1)
+			}
+		}
+	default:
+		ba.transform(x, y, due.To)
+	}
+}
+
+// transform changes the material of a cell, keeping the water it can hold.
+func (ba *ErosionBoard) transform(x, y int, m levels.Material) {
+	ba.soil[x][y] = m
+	ba.generation++
+	ba.Rocks[x][y] = m == levels.Rock
+	ba.Rain[x][y] = m == levels.Rain
+	ba.holding[x][y] = ba.soilHolding(m)
+	ba.values[x][y][1] = soilCell(m)[1]
+	ba.values[x][y][0] = min32(ba.values[x][y][0], ba.holding[x][y][0])
+	ba.wet[x][y], ba.dry[x][y] = 0, -1
+}
+
+// swap trades two cells along with their water and how long they have
+// been wet or dry, so washed sand keeps moving on the next steps.
+func (ba *ErosionBoard) swap(x0, y0, x1, y1 int) {
+	v0, v1 := ba.values[x0][y0], ba.values[x1][y1]
+	m0, m1 := ba.soil[x0][y0], ba.soil[x1][y1]
+	w0, w1 := ba.wet[x0][y0], ba.wet[x1][y1]
+	d0, d1 := ba.dry[x0][y0], ba.dry[x1][y1]
+	ba.transform(x0, y0, m1)
+	ba.transform(x1, y1, m0)
+	ba.values[x0][y0], ba.values[x1][y1] = v1, v0
+	ba.wet[x0][y0], ba.wet[x1][y1] = w1, w0
+	ba.dry[x0][y0], ba.dry[x1][y1] = d1, d0
+}
@@ -0,0 +1,192 @@
+package boards
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// soak fills a cell to its capacity.
+func soak(b *ErosionBoard, x, y int) {
+	b.GetState()[x][y][0] = b.limits(x, y)[0]
+}
+
+func TestSoakedSoilTurnsToMud(t *testing.T) {
+	b := NewErosionBoard([4]Boundary{}, ErosionRules{
+		levels.LooseSoil: {Wet: &Transition{Threshold: 0.5, Ticks: 10, To: levels.Mud}},
+		levels.Mud:       {Dry: &Transition{Threshold: 0.3, Ticks: 10, To: levels.LooseSoil}},
+	})
+	b.Load([][]levels.Material{{levels.LooseSoil}})
+	soak(b, 0, 0)
+	for i := 0; i < 9; i++ {
+		b.Update()
+	}
+	if m := b.Materials()[0][0]; m != levels.LooseSoil {
+		t.Fatalf("cell turned to %v before being wet long enough", m)
+	}
+	g := b.Generation()
+	b.Update()
+	if m := b.Materials()[0][0]; m != levels.Mud {
+		t.Fatalf("soaked loose soil is %v, want mud", m)
+	}
+	if b.Generation() == g {
+		t.Error("turning to mud should change the generation of the materials")
+	}
+	if v, c := b.GetState()[0][0][0], b.limits(0, 0)[0]; v > c {
+		t.Errorf("mud holds %f, over its capacity %f", v, c)
+	}
+
+	// The new mud is still wet, then dries
+	b.Update()
+	b.GetState()[0][0][0] = 0
+	for i := 0; i < 10; i++ {
+		b.Update()
+	}
+	if m := b.Materials()[0][0]; m != levels.LooseSoil {
+		t.Errorf("dry mud is %v, want loose soil", m)
+	}
+}
+
+func TestSandWashesDown(t *testing.T) {
+	b := NewErosionBoard([4]Boundary{}, ErosionRules{
+		levels.Sand: {Wet: &Transition{Threshold: 0.5, Ticks: 1, Wash: true}},
+	})
+	b.Load([][]levels.Material{{levels.Sand, levels.Air, levels.Air, levels.Rock}})
+	for i := 0; i < 3; i++ {
+		soak(b, 0, i)
+		b.Update()
+	}
+	want := []levels.Material{levels.Air, levels.Air, levels.Sand, levels.Rock}
+	for y, m := range b.Materials()[0] {
+		if m != want[y] {
+			t.Fatalf("column is %v, want %v", b.Materials()[0], want)
+		}
+	}
+}
+
+func TestWashedSandKeepsFalling(t *testing.T) {
+	b := NewErosionBoard([4]Boundary{}, ErosionRules{
+		levels.Sand: {Wet: &Transition{Threshold: 0.5, Ticks: 3, Wash: true}},
+	})
+	col := []levels.Material{levels.Sand}
+	for i := 0; i < 5; i++ {
+		col = append(col, levels.Air)
+	}
+	b.Load([][]levels.Material{append(col, levels.Rock)})
+
+	// Once wet long enough, the sand falls one cell per step
+	y := 0
+	for step := 1; step <= 7; step++ {
+		soak(b, 0, y)
+		b.Update()
+		want := 0
+		if step >= 3 {
+			want = step - 2
+		}
+		for b.Materials()[0][y] != levels.Sand {
+			y++
+		}
+		if y != want {
+			t.Fatalf("step %d: sand is on row %d, want %d", step, y, want)
+		}
+	}
+}
+
+func TestDryTerrainStays(t *testing.T) {
+	b := NewErosionBoard([4]Boundary{}, ErosionRules{
+		levels.Clay: {Dry: &Transition{Threshold: 0.05, Ticks: 10, To: levels.HardSoil}},
+	})
+	b.Load([][]levels.Material{{levels.Clay}})
+	for i := 0; i < 100; i++ {
+		b.Update()
+	}
+	if m := b.Materials()[0][0]; m != levels.Clay {
+		t.Fatalf("clay that was never wet turned to %v", m)
+	}
+
+	soak(b, 0, 0)
+	b.Update()
+	b.GetState()[0][0][0] = 0
+	for i := 0; i < 10; i++ {
+		b.Update()
+	}
+	if m := b.Materials()[0][0]; m != levels.HardSoil {
+		t.Errorf("clay dried after being wet is %v, want hard soil", m)
+	}
+}
+
+func TestErosionReset(t *testing.T) {
+	b := NewErosionBoard([4]Boundary{}, ErosionRules{
+		levels.LooseSoil: {Wet: &Transition{Threshold: 0.5, Ticks: 1, To: levels.Mud}},
+	})
+	soil := [][]levels.Material{{levels.LooseSoil}, {levels.Air}}
+	b.Load(soil)
+	b.Paint(1, 0, levels.Clay)
+	soak(b, 0, 0)
+	b.Update()
+	if m := b.Materials()[0][0]; m != levels.Mud {
+		t.Fatalf("soaked loose soil is %v, want mud", m)
+	}
+
+	g := b.Generation()
+	b.Reset()
+	if b.Generation() == g {
+		t.Error("reset should change the generation of the materials")
+	}
+	if got := b.Materials(); got[0][0] != levels.LooseSoil || got[1][0] != levels.Clay {
+		t.Errorf("reset terrain is %v, want the loaded soil with the painted clay", got)
+	}
+	if soil[0][0] != levels.LooseSoil || soil[1][0] != levels.Air {
+		t.Error("erosion changed the loaded level")
+	}
+}
+
+func TestParseErosionRules(t *testing.T) {
+	base := DefaultErosionRules()
+	rules, err := ParseErosionRules(strings.NewReader(`{
+		"Clay": {"dry": {"threshold": 0.2, "ticks": 50, "to": "rock"}},
+		"rock": {"wet": {"threshold": 1, "ticks": 10, "wash": true}}
+	}`), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := rules[levels.Clay].Dry; d == nil || *d != (Transition{Threshold: 0.2, Ticks: 50, To: levels.Rock}) {
+		t.Errorf("clay dries with %+v", d)
+	}
+	if w := rules[levels.Rock].Wet; w == nil || !w.Wash {
+		t.Errorf("rock gets wet with %+v, want a wash", w)
+	}
+	if rules[levels.Sand] != base[levels.Sand] {
+		t.Error("sand should keep its default transitions")
+	}
+	if *base[levels.Clay].Dry != *DefaultErosionRules()[levels.Clay].Dry {
+		t.Error("parsing changed the base rules")
+	}
+
+	for _, in := range []string{
+		`{"lava": {"wet": {"to": "rock"}}}`,
+		`{"clay": {"wet": {"to": "lava"}}}`,
+		`{"clay": {"soon": {"to": "rock"}}}`,
+		`{"clay": `,
+	} {
+		if _, err := ParseErosionRules(strings.NewReader(in), base); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestMaterialImpermeability(t *testing.T) {
+	for m := levels.Empty; m.Valid(); m++ {
+		if _, ok := impermeability[m]; !ok {
+			t.Errorf("material %d has no impermeability", m)
+		}
+	}
+	// Mud holds the most water, it shouldn't resist it more than any soil
+	mud := soilCell(levels.Mud)[1]
+	for _, m := range []levels.Material{levels.LooseSoil, levels.HardSoil, levels.Sand, levels.Clay} {
+		if k := soilCell(m)[1]; mud > k {
+			t.Errorf("mud has impermeability %f, more than %d at %f", mud, m, k)
+		}
+	}
+}
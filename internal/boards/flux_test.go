@@ -0,0 +1,52 @@
+package boards
+
+import (
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+func TestFluxBalancesUpdate(t *testing.T) {
+	soil := [][]levels.Material{
+		{levels.Rain, levels.Sand, levels.Air},
+		{levels.Air, levels.LooseSoil, levels.Clay},
+		{levels.Rock, levels.Air, levels.Air},
+	}
+	hum, rocks, rain := MakeSoilGrid(soil)
+	ba := &HumidityBoard{Rocks: rocks, Rain: rain}
+	ba.Setup(hum)
+	if ba.Flux() != nil {
+		t.Fatal("flux should be nil before the first step")
+	}
+
+	for i := 0; i < 5; i++ {
+		before := ba.GetState()
+		ba.Update()
+		for x, row := range ba.GetState() {
+			for y, v := range row {
+				f := ba.Flux()[x][y]
+				sum := f[0] + f[1] + f[2] + f[3]
+				if got := v[0] - before[x][y][0]; abs32(got-sum) > 1e-3 {
+					t.Fatalf("step %d cell (%d, %d): changed by %f but took %f", i, x, y, got, sum)
+				}
+			}
+		}
+	}
+}
+
+func TestFlowPointsAwayFromRain(t *testing.T) {
+	hum := [][]mgl32.Vec2{{{1023, 1}}, {{0, 1}}, {{0, 1}}}
+	ba := &HumidityBoard{
+		Rocks: [][]bool{{false}, {false}, {false}},
+		Rain:  [][]bool{{true}, {false}, {false}},
+	}
+	ba.Setup(hum)
+	ba.Update()
+	if f := ba.FlowAt(1, 0); f[0] <= 0 || f[1] != 0 {
+		t.Errorf("flow next to the rain is %v, want it along +x", f)
+	}
+	if f := ba.FlowAt(0, 0); f != (mgl32.Vec2{}) {
+		t.Errorf("rain cells should have no flow, got %v", f)
+	}
+}
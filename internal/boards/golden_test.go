@@ -0,0 +1,105 @@
+package boards
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+// Run `go test ./internal/boards -update` to rewrite the goldens after an
+// intentional change to the simulation or its colours.
+var update = flag.Bool("update", false, "rewrite the golden images")
+
+const (
+	goldenProject = "../../soil-demo.ldtk"
+	// Largest difference allowed per colour channel, float rounding may vary
+	// between platforms
+	goldenTolerance = 2
+)
+
+var goldenTicks = []int{0, 10, 100, 1000}
+
+func loadGoldenLevels(t *testing.T) []levels.Level {
+	t.Helper()
+	lvls, err := (&levels.LDtkSource{Path: goldenProject}).Levels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lvls
+}
+
+func checkGolden(t *testing.T, name string, img *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".png")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%s, run with -update to create it", err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := util.AsRGBA(golden)
+	if want.Bounds() != img.Bounds() {
+		t.Fatalf("%s: size is %v, golden is %v", name, img.Bounds(), want.Bounds())
+	}
+	for i := range img.Pix {
+		if d := int(img.Pix[i]) - int(want.Pix[i]); d > goldenTolerance || d < -goldenTolerance {
+			x, y := i/4%img.Rect.Dx(), i/4/img.Rect.Dx()
+			t.Fatalf("%s: pixel (%d, %d) is %v, golden is %v", name, x, y, img.At(x, y), want.At(x, y))
+		}
+	}
+}
+
+func TestHumidityBoardGolden(t *testing.T) {
+	for _, lvl := range loadGoldenLevels(t) {
+		b := &HumidityBoard{}
+		b.Load(lvl.Soil)
+		b.Hover(-1, -1)
+
+		tick := 0
+		for _, until := range goldenTicks {
+			for ; tick < until; tick++ {
+				b.Update()
+			}
+			img := image.NewRGBA(image.Rect(0, 0, len(lvl.Soil), len(lvl.Soil[0])))
+			b.Draw(img)
+			checkGolden(t, fmt.Sprintf("humidity_%s_%04d", lvl.Identifier, tick), img)
+		}
+	}
+}
+
+func TestEnumBoardGolden(t *testing.T) {
+	for _, lvl := range loadGoldenLevels(t) {
+		b := &EnumBoard{}
+		b.Setup(MakeColorGrid(lvl.Soil))
+		b.Hover(-1, -1)
+
+		img := image.NewRGBA(image.Rect(0, 0, len(lvl.Soil), len(lvl.Soil[0])))
+		b.Draw(img)
+		checkGolden(t, "soil_"+lvl.Identifier, img)
+	}
+}
@@ -2,10 +2,11 @@ package boards
 
 import (
 	"image/color"
+	"image/draw"
 	"math"
 
 	"github.com/go-gl/mathgl/mgl32"
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/joelschutz/soil-demo/internal/levels"
 	"github.com/joelschutz/soil-demo/util"
 )
 
@@ -56,12 +57,34 @@ The fields `Rocks` and `Rain` just represent cells that are totally impermeable
 or sources of humidity, respectively.
 */
 
+// HighImpermeability is the impermeability from which cells hold their
+// humidity forever.
+const HighImpermeability = (math.MaxFloat32 / 5) * 4
+
 // PIN
 type HumidityBoard struct {
 	initValues  [][]mgl32.Vec2 // [humidity, impermeability]
 	values      [][]mgl32.Vec2 // [humidity, impermeability]
+	soil        [][]levels.Material
 	Rocks, Rain [][]bool
-	hvrX, hvrY  int
+	// Edges sets how each side of the board treats the cells beyond it,
+	// closed by default
+	Edges [4]Boundary
+	// Outside, if set, supplies the cells past the edges, like those of
+	// neighbouring chunks. Edges apply where it has no cell.
+	Outside func(x, y int) (mgl32.Vec2, bool)
+	// OutsideLimits, if set, supplies the [capacity, field capacity] of
+	// the cells of Outside.
+	OutsideLimits func(x, y int) (mgl32.Vec2, bool)
+	// Holding sets the water each material takes, the default holding of
+	// the soil if nil. It applies on Load and Paint.
+	Holding map[levels.Material]Holding
+	flux    [][]mgl32.Vec4
+	// holding is the [capacity, field capacity] of each cell, set on Load
+	holding [][]mgl32.Vec2
+	// generation counts the changes to soil
+	generation uint
+	hvrX, hvrY int
 }
 
 func (ba *HumidityBoard) Size() (int, int) {
@@ -113,9 +136,11 @@ Then we clamp to new value to a max of 1023.
 func (ba *HumidityBoard) Update() error {
 	// Armazenamos o estado inicial do espaço para servir de referencia
 	m0 := [][]mgl32.Vec2{}
+	flux := [][]mgl32.Vec4{}
 
 	for x, row := range ba.values {
 		m0 = append(m0, []mgl32.Vec2{})
+		flux = append(flux, make([]mgl32.Vec4, len(row)))
 		for y, v0 := range row {
 			m0[x] = append(m0[x], v0)
 			// Cell names
@@ -123,43 +148,55 @@ func (ba *HumidityBoard) Update() error {
 			// |v1|v0|v2|
 			//    |v4|
 			// Pulamos o calculo de fontes de umidade e células com alto impermeabilidade
-			if ba.Rain[x][y] || v0[1] >= (math.MaxFloat32/5)*4 {
+			if ba.Rain[x][y] || v0[1] >= HighImpermeability {
 				continue
 			}
 
-			// Assumimos que as bordas são células secas e impermeáveis
-			v1 := mgl32.Vec2{0, math.MaxFloat32}
-			v2 := mgl32.Vec2{0, math.MaxFloat32}
-			v3 := mgl32.Vec2{0, math.MaxFloat32}
-			v4 := mgl32.Vec2{0, math.MaxFloat32}
+			// Vizinhos fora do espaço seguem a condição de contorno da borda
+			v1 := ba.neighbour(x-1, y)
+			v2 := ba.neighbour(x+1, y)
+			v3 := ba.neighbour(x, y-1)
+			v4 := ba.neighbour(x, y+1)
 
-			// Verificamos se o vizinho existe e aplicamos os valores corretos
-			if x > 0 {
-				v1 = ba.values[x-1][y]
-			}
-			if x < len(ba.values)-1 {
-				v2 = ba.values[x+1][y]
-			}
-			if y > 0 {
-				v3 = ba.values[x][y-1]
-			}
-			if y < len(ba.values[0])-1 {
-				v4 = ba.values[x][y+1]
-			}
+			// Cada vizinho só troca a água que pode ceder ou receber
+			v1[0] = freeWater(v0[0], v1[0], ba.limits(x-1, y))
+			v2[0] = freeWater(v0[0], v2[0], ba.limits(x+1, y))
+			v3[0] = freeWater(v0[0], v3[0], ba.limits(x, y-1))
+			v4[0] = freeWater(v0[0], v4[0], ba.limits(x, y+1))
 
 			// Calculamos a média aritmética ponderada
-			r := ((v0[0] * (v0[1])) + (v1[0] / v1[1]) + (v2[0] / v2[1]) + (v3[0] / v3[1]) + (v4[0] / v4[1])) / (v0[1] + (1 / v1[1]) + (1 / v2[1]) + (1 / v3[1]) + (1 / v4[1]))
+			d := v0[1] + (1 / v1[1]) + (1 / v2[1]) + (1 / v3[1]) + (1 / v4[1])
+			r := ((v0[0] * (v0[1])) + (v1[0] / v1[1]) + (v2[0] / v2[1]) + (v3[0] / v3[1]) + (v4[0] / v4[1])) / d
+
+			// A variação da célula é a soma do que entra por cada vizinho
+			flux[x][y] = mgl32.Vec4{
+				(v1[0] - v0[0]) / v1[1] / d,
+				(v2[0] - v0[0]) / v2[1] / d,
+				(v3[0] - v0[0]) / v3[1] / d,
+				(v4[0] - v0[0]) / v4[1] / d,
+			}
 
-			// Limitamos os valores a um máximo de 1023
-			if r > 1023 {
-				r = 1023
+			// A célula retém sua água até a capacidade de campo e não passa da
+			// sua porosidade. O fluxo acompanha o limite.
+			c0 := ba.limits(x, y)
+			limited := r
+			if hold := min32(v0[0], c0[1]); limited < hold {
+				limited = hold
+			}
+			if limited > c0[0] {
+				limited = c0[0]
 			}
+			if limited != r && r != v0[0] {
+				flux[x][y] = flux[x][y].Mul((limited - v0[0]) / (r - v0[0]))
+			}
+			r = limited
 
 			// Atualizamos espaço com novo valor de umidade
 			m0[x][y][0] = r
 		}
 	}
 	ba.values = m0
+	ba.flux = flux
 	return nil
 }
 
@@ -185,15 +222,19 @@ for games development. Keep in mind that this algorithm is synchronous, but it's
 possible to paralelize it.
 */
 
-func (ba *HumidityBoard) Draw(screen *ebiten.Image) {
+func (ba *HumidityBoard) Draw(screen draw.Image) {
 	var clr color.Color
 	for x, row := range ba.values {
 		for y, v0 := range row {
 			if ba.Rocks[x][y] {
 				clr = color.RGBA{255, 255, 255, uint8((v0[1] / math.MaxFloat32) * 255)}
 			} else {
-
-				clr = util.HSVColor{uint16((v0[0] / 1023) * 240), 255, 255}
+				// Saturated cells are blue, whatever their porosity
+				sat := float32(0)
+				if c := ba.limits(x, y)[0]; c > 0 {
+					sat = min32(v0[0]/c, 1)
+				}
+				clr = util.HSVColor{H: uint16(sat * 240), S: 255, V: 255}
 			}
 			if x == ba.hvrX && y == ba.hvrY {
 				clr = color.Black
@@ -203,17 +244,37 @@ func (ba *HumidityBoard) Draw(screen *ebiten.Image) {
 	}
 }
 
+// Flux returns the humidity each cell took from its left, right, top and
+// bottom neighbours on the last step, negative where it gave some away.
+// Sources and highly impermeable cells take nothing. It is nil before the
+// first step.
+func (ba *HumidityBoard) Flux() [][]mgl32.Vec4 {
+	return ba.flux
+}
+
+// FlowAt returns the net direction water moved through a cell on the last
+// step, as the mean humidity crossing its faces along x and y.
+func (ba *HumidityBoard) FlowAt(x, y int) mgl32.Vec2 {
+	if ba.flux == nil {
+		return mgl32.Vec2{}
+	}
+	f := ba.flux[x][y]
+	// Water taken from the left moves right, and so on
+	return mgl32.Vec2{(f[0] - f[1]) / 2, (f[2] - f[3]) / 2}
+}
+
 func (ba *HumidityBoard) Layout(outsideWidth int, outsideHeight int) (screenWidth int, screenHeight int) {
 	return 0, 0
 }
 
 func (ba *HumidityBoard) Setup(init [][]mgl32.Vec2) {
 	ba.initValues = init
-	ba.values = init
+	ba.Reset()
 }
 
 func (ba *HumidityBoard) Reset() error {
 	ba.values = ba.initValues
+	ba.flux = nil
 	return nil
 }
 
@@ -221,11 +282,70 @@ func (ba *HumidityBoard) GetState() [][]mgl32.Vec2 {
 	return ba.values
 }
 
-func (ba *HumidityBoard) Click(btn ebiten.MouseButton) {
-	if btn == ebiten.MouseButtonLeft {
-		ba.Rocks[ba.hvrX][ba.hvrY] = true
-	} else if btn == ebiten.MouseButtonRight {
-		ba.Rocks[ba.hvrX][ba.hvrY] = false
+// SetState replaces the current values, keeping the ones used by Reset.
+func (ba *HumidityBoard) SetState(values [][]mgl32.Vec2) {
+	ba.values = values
+}
+
+// Load sets the board up from a copy of soil.
+func (ba *HumidityBoard) Load(soil [][]levels.Material) {
+	ba.soil = make([][]levels.Material, len(soil))
+	for x, row := range soil {
+		ba.soil[x] = append([]levels.Material{}, row...)
+	}
+	ba.generation++
+	hum, rocks, rain := MakeSoilGrid(ba.soil)
+	ba.Rocks, ba.Rain = rocks, rain
+	ba.holding = util.MakeMatrixWH(len(soil), len(soil[0]), mgl32.Vec2{})
+	for x, row := range ba.soil {
+		for y, m := range row {
+			ba.holding[x][y] = ba.soilHolding(m)
+		}
+	}
+	ba.Setup(hum)
+}
+
+// Materials is nil for boards set up from cells instead of a level.
+func (ba *HumidityBoard) Materials() [][]levels.Material {
+	return ba.soil
+}
+
+func (ba *HumidityBoard) Generation() uint {
+	return ba.generation
+}
+
+func (ba *HumidityBoard) Paint(x, y int, m levels.Material) {
+	if w, h := ba.Size(); x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+	if ba.soil != nil {
+		ba.soil[x][y] = m
+		ba.generation++
+	}
+	cell := soilCell(m)
+	ba.values[x][y] = cell
+	ba.initValues[x][y] = cell
+	if ba.holding != nil {
+		ba.holding[x][y] = ba.soilHolding(m)
+	}
+	ba.Rocks[x][y] = m == levels.Rock
+	ba.Rain[x][y] = m == levels.Rain
+}
+
+func (ba *HumidityBoard) Click(btn Button) {
+	paintClick(ba, ba.hvrX, ba.hvrY, ba.Rocks, btn)
+}
+
+// paintClick paints a rock on the hovered cell of a board with the left
+// button and erases it, leaving air, with the right one.
+func paintClick(b Simulation, x, y int, rocks [][]bool, btn Button) {
+	if w, h := b.Size(); x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+	if btn == LeftButton {
+		b.Paint(x, y, levels.Rock)
+	} else if btn == RightButton && rocks[x][y] {
+		b.Paint(x, y, levels.Air)
 	}
 }
 
@@ -246,3 +366,96 @@ func MakeHumidityGrid(rockMask, rainMask [][]bool) [][]mgl32.Vec2 {
 	util.ApplyMaskOnMatrix(grid, rainMask, mgl32.Vec2{1023, 1})
 	return grid
 }
+
+// MakeSoilGrid converts the materials of a level to humidity cells, along
+// with the masks of its rocks and rain.
+func MakeSoilGrid(soil [][]levels.Material) (hum [][]mgl32.Vec2, rocks, rain [][]bool) {
+	w, h := len(soil), len(soil[0])
+	// Create Air Grid
+	hum = util.MakeMatrixWH(w, h, mgl32.Vec2{0, 1})
+	rocks = util.MakeMatrixWH(w, h, false)
+	rain = util.MakeMatrixWH(w, h, false)
+	for i, row := range hum {
+		for j := range row {
+			hum[i][j] = soilCell(soil[i][j])
+			rocks[i][j] = soil[i][j] == levels.Rock
+			rain[i][j] = soil[i][j] == levels.Rain
+		}
+	}
+	return hum, rocks, rain
+}
+
+// Holding is the water a material takes, as a share of a full cell:
+// Porosity is the most it can hold, FieldCapacity what it keeps against
+// drainage.
+type Holding struct {
+	Porosity, FieldCapacity float32
+}
+
+// holding is the default Holding of the soil. Materials missing take up to
+// a full cell and hold nothing.
+var holding = map[levels.Material]Holding{
+	levels.LooseSoil: {0.55, 0.25},
+	levels.HardSoil:  {0.45, 0.3},
+	levels.Sand:      {0.4, 0.1},
+	levels.Clay:      {0.5, 0.4},
+	levels.Rock:      {0, 0},
+	levels.Mud:       {0.7, 0.6},
+}
+
+// soilHolding is the [capacity, field capacity] of a material, in humidity.
+func (ba *HumidityBoard) soilHolding(m levels.Material) mgl32.Vec2 {
+	table := ba.Holding
+	if table == nil {
+		table = holding
+	}
+	h, ok := table[m]
+	if !ok {
+		return mgl32.Vec2{1023, 0}
+	}
+	return mgl32.Vec2{h.Porosity * 1023, h.FieldCapacity * 1023}
+}
+
+// freeWater is the humidity a cell at v0 sees in a neighbour at vn with
+// limits c. A wetter neighbour only gives its water above field capacity
+// and a drier one at capacity takes no more; blocked water looks like v0,
+// so it isn't exchanged.
+func freeWater(v0, vn float32, c mgl32.Vec2) float32 {
+	if vn > v0 {
+		if c[1] > v0 {
+			return v0 + float32(math.Max(0, float64(vn-c[1])))
+		}
+		return vn
+	}
+	if vn >= c[0] {
+		return v0
+	}
+	return vn
+}
+
+// impermeability is how much each material resists losing or taking water.
+// Mud is as permeable as the loose soil it comes from.
+var impermeability = map[levels.Material]float32{
+	levels.Empty:     1,
+	levels.Air:       1,
+	levels.LooseSoil: 25,
+	levels.HardSoil:  125,
+	levels.Sand:      625,
+	levels.Clay:      3125,
+	levels.Rock:      math.MaxFloat32,
+	levels.Rain:      1,
+	levels.Mud:       25,
+}
+
+// soilCell is the initial humidity and impermeability of a material.
+// Materials missing from impermeability behave like air.
+func soilCell(m levels.Material) mgl32.Vec2 {
+	k, ok := impermeability[m]
+	if !ok {
+		k = 1
+	}
+	if m == levels.Rain {
+		return mgl32.Vec2{1023, k}
+	}
+	return mgl32.Vec2{0, k}
+}
@@ -0,0 +1,273 @@
+package boards
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+// Invariants of the diffusion rule, checked on random grids over many
+// steps. A failing grid is shrunk before being reported.
+
+const (
+	propertyRuns  = 200
+	propertySteps = 200
+)
+
+// diffusionCase is a board setup: materials, the initial humidity of every
+// cell and how many steps to run.
+type diffusionCase struct {
+	soil  [][]levels.Material
+	hum   [][]float32
+	steps int
+}
+
+func randomCase(rng *rand.Rand, size, steps int) diffusionCase {
+	c := diffusionCase{steps: steps}
+	// Materials from Air to Rock, rain is sprinkled on top
+	mats := util.MakeRandMatrixUint8(rng, size, int(levels.Rock))
+	rain := util.MakeRandMatrixBool(rng, size, 10)
+	wet := util.MakeRandMatrixUint8(rng, size, 255)
+	for x := 0; x < size; x++ {
+		c.soil = append(c.soil, make([]levels.Material, size))
+		c.hum = append(c.hum, make([]float32, size))
+		for y := 0; y < size; y++ {
+			c.soil[x][y] = levels.Material(mats[x][y])
+			if rain[x][y] {
+				c.soil[x][y] = levels.Rain
+			}
+			c.hum[x][y] = float32(wet[x][y]-1) * 1023 / 254
+		}
+	}
+	return c
+}
+
+func (c diffusionCase) board() *HumidityBoard {
+	b := &HumidityBoard{}
+	b.Load(c.soil)
+	for x, row := range b.GetState() {
+		for y := range row {
+			if !b.Rain[x][y] {
+				row[y][0] = c.hum[x][y]
+			}
+		}
+	}
+	return b
+}
+
+// check runs the case and returns the first broken invariant.
+func (c diffusionCase) check() error {
+	b := c.board()
+	init := b.GetState()
+	for step := 1; step <= c.steps; step++ {
+		prev := b.GetState()
+		b.Update()
+		for x, row := range b.GetState() {
+			for y, v := range row {
+				v0, lim := init[x][y], b.limits(x, y)
+				moving := !b.Rain[x][y] && v0[1] < HighImpermeability
+				switch {
+				case math.IsNaN(float64(v[0])):
+					return fmt.Errorf("step %d: cell (%d, %d) is NaN", step, x, y)
+				case v[0] > 1023:
+					return fmt.Errorf("step %d: cell (%d, %d) is over 1023: %v", step, x, y, v[0])
+				case v[0] < 0:
+					return fmt.Errorf("step %d: cell (%d, %d) is negative: %v", step, x, y, v[0])
+				case b.Rain[x][y] && v != v0:
+					return fmt.Errorf("step %d: rain cell (%d, %d) changed from %v to %v", step, x, y, v0, v)
+				case v0[1] >= HighImpermeability && v != v0:
+					return fmt.Errorf("step %d: impermeable cell (%d, %d) changed from %v to %v", step, x, y, v0, v)
+				case moving && v[0] > lim[0]:
+					return fmt.Errorf("step %d: cell (%d, %d) is over its capacity %v: %v", step, x, y, lim[0], v[0])
+				case moving && v[0] < min32(prev[x][y][0], lim[1]):
+					return fmt.Errorf("step %d: cell (%d, %d) drained from %v to %v, below its field capacity %v", step, x, y, prev[x][y][0], v[0], lim[1])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// crop returns the case restricted to the cells [x0, x1) x [y0, y1).
+func (c diffusionCase) crop(x0, y0, x1, y1 int) diffusionCase {
+	out := diffusionCase{steps: c.steps}
+	for x := x0; x < x1; x++ {
+		out.soil = append(out.soil, append([]levels.Material{}, c.soil[x][y0:y1]...))
+		out.hum = append(out.hum, append([]float32{}, c.hum[x][y0:y1]...))
+	}
+	return out
+}
+
+// shrink looks for a smaller case failing prop: fewer steps, then a smaller
+// grid by dropping edge rows and columns, then simpler cells.
+func shrink(c diffusionCase, prop func(diffusionCase) error) diffusionCase {
+	for c.steps > 1 {
+		fewer := c
+		fewer.steps = c.steps / 2
+		if prop(fewer) == nil {
+			break
+		}
+		c = fewer
+	}
+	for changed := true; changed; {
+		changed = false
+		w, h := len(c.soil), len(c.soil[0])
+		for _, r := range [][4]int{{1, 0, w, h}, {0, 0, w - 1, h}, {0, 1, w, h}, {0, 0, w, h - 1}} {
+			if r[2]-r[0] < 1 || r[3]-r[1] < 1 {
+				continue
+			}
+			if smaller := c.crop(r[0], r[1], r[2], r[3]); prop(smaller) != nil {
+				c, changed = smaller, true
+				break
+			}
+		}
+	}
+	// Turn cells to dry air one by one while the case still fails
+	for x, row := range c.soil {
+		for y := range row {
+			mat, hum := c.soil[x][y], c.hum[x][y]
+			if mat == levels.Air && hum == 0 {
+				continue
+			}
+			c.soil[x][y], c.hum[x][y] = levels.Air, 0
+			if prop(c) == nil {
+				c.soil[x][y], c.hum[x][y] = mat, hum
+			}
+		}
+	}
+	return c
+}
+
+func (c diffusionCase) String() string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "%d steps, material:humidity by row\n", c.steps)
+	for y := range c.soil[0] {
+		for x := range c.soil {
+			fmt.Fprintf(&sb, " %d:%-6.1f", c.soil[x][y], c.hum[x][y])
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func reportFailure(t *testing.T, c diffusionCase, err error) {
+	t.Helper()
+	min := shrink(c, diffusionCase.check)
+	t.Fatalf("%s\nminimal case: %s\n%s", err, min.check(), min)
+}
+
+func TestHumidityUpdateInvariants(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < propertyRuns; i++ {
+		c := randomCase(rng, 1+rng.Intn(12), propertySteps)
+		if err := c.check(); err != nil {
+			reportFailure(t, c, err)
+		}
+	}
+}
+
+func FuzzHumidityUpdate(f *testing.F) {
+	f.Add(int64(0), uint8(8), uint8(50))
+	f.Add(int64(1), uint8(1), uint8(1))
+	f.Add(int64(42), uint8(16), uint8(255))
+	f.Fuzz(func(t *testing.T, seed int64, size, steps uint8) {
+		if size == 0 || size > 32 {
+			t.Skip()
+		}
+		c := randomCase(rand.New(rand.NewSource(seed)), int(size), int(steps))
+		if err := c.check(); err != nil {
+			reportFailure(t, c, err)
+		}
+	})
+}
+
+// The shrinker must keep a case failing, here with a deliberately broken
+// property: no cell may ever get wetter.
+func TestShrinkKeepsFailure(t *testing.T) {
+	neverWetter := func(c diffusionCase) error {
+		b := c.board()
+		for i := 0; i < c.steps; i++ {
+			b.Update()
+		}
+		for x, row := range b.GetState() {
+			for y, v := range row {
+				if !b.Rain[x][y] && v[0] > c.hum[x][y] {
+					return fmt.Errorf("cell (%d, %d) got wetter", x, y)
+				}
+			}
+		}
+		return nil
+	}
+
+	c := randomCase(rand.New(rand.NewSource(3)), 8, 20)
+	if neverWetter(c) == nil {
+		t.Fatal("the random case should break the property")
+	}
+	min := shrink(c, neverWetter)
+	if neverWetter(min) == nil {
+		t.Fatalf("shrunk case passes:\n%s", min)
+	}
+	// A rain cell next to a dry one is enough
+	if w, h := len(min.soil), len(min.soil[0]); w*h > 2 || min.steps != 1 {
+		t.Errorf("case not minimal:\n%s", min)
+	}
+}
+
+func TestPaint(t *testing.T) {
+	soil := [][]levels.Material{{levels.Rain}, {levels.Air}, {levels.Air}}
+	b := &HumidityBoard{}
+	b.Load(soil)
+	b.Hover(1, 0)
+	b.Click(LeftButton)
+	for i := 0; i < 10; i++ {
+		b.Update()
+	}
+	if v := b.GetState()[2][0][0]; v != 0 {
+		t.Errorf("water crossed a painted rock, humidity behind it is %f", v)
+	}
+	if m := b.Materials()[1][0]; m != levels.Rock {
+		t.Errorf("painted cell is %v, want rock", m)
+	}
+	if soil[1][0] != levels.Air {
+		t.Error("painting changed the loaded level")
+	}
+
+	b.Reset()
+	if !b.Rocks[1][0] || b.GetState()[1][0][1] < HighImpermeability {
+		t.Error("the painted rock should survive a reset")
+	}
+	b.Click(RightButton)
+	if b.Rocks[1][0] || b.Materials()[1][0] != levels.Air {
+		t.Error("right click should erase the rock to air")
+	}
+}
+
+func TestFieldCapacity(t *testing.T) {
+	for _, m := range []levels.Material{levels.Sand, levels.Clay} {
+		b := &HumidityBoard{}
+		b.Load([][]levels.Material{{m}, {m}, {m}, {m}})
+		lim := b.limits(0, 0)
+		// Keep the limits of the material but let the water move fast
+		for _, row := range b.GetState() {
+			row[0][1] = 1
+		}
+		b.GetState()[0][0][0] = lim[0]
+		for i := 0; i < 1000; i++ {
+			b.Update()
+		}
+		v := b.GetState()
+		if d := v[0][0][0] - lim[1]; d < 0 || d > 1 {
+			t.Errorf("%v: a saturated cell should drain to its field capacity %f, got %f", m, lim[1], v[0][0][0])
+		}
+		for x := range v {
+			if v[x][0][0] > lim[1]+1 {
+				t.Errorf("%v: cell %d holds %f, over field capacity it should drain", m, x, v[x][0][0])
+			}
+		}
+	}
+}
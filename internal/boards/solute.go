@@ -0,0 +1,223 @@
+package boards
+
+import (
+	"fmt"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+const (
+	// NutrientEntity names the level entities that are solute sources. Their
+	// "amount" property sets the solute they hold, DefaultSourceAmount if
+	// missing.
+	NutrientEntity      = "Nutrient"
+	DefaultSourceAmount = 1023
+)
+
+var soluteColor = color.NRGBA{0x6a, 0xbe, 0x30, 0xff}
+
+// adsorption is the share of the dissolved solute a material holds back
+// when water leaves it.
+var adsorption = map[levels.Material]float32{
+	levels.LooseSoil: 0.3,
+	levels.HardSoil:  0.5,
+	levels.Sand:      0.05,
+	levels.Clay:      0.8,
+	levels.Rock:      1,
+	levels.Mud:       0.6,
+}
+
+type soluteSource struct {
+	x, y   int
+	amount float32
+}
+
+// SoluteBoard runs the humidity rule with a solute dissolved in the water,
+// fed by the Nutrient entities of the level and held back by each
+// material.
+type SoluteBoard struct {
+	HumidityBoard
+	solute  [][]float32
+	sources []soluteSource
+}
+
+func NewSoluteBoard(edges [4]Boundary) *SoluteBoard {
+	return &SoluteBoard{HumidityBoard: HumidityBoard{Edges: edges}}
+}
+
+func (ba *SoluteBoard) Setup(init [][]mgl32.Vec2) {
+	ba.HumidityBoard.Setup(init)
+	ba.Reset()
+}
+
+func (ba *SoluteBoard) Load(soil [][]levels.Material) {
+	ba.HumidityBoard.Load(soil)
+	ba.Reset()
+}
+
+func (ba *SoluteBoard) Reset() error {
+	ba.HumidityBoard.Reset()
+	ba.solute = util.MakeMatrixWH(len(ba.values), len(ba.values[0]), float32(0))
+	ba.feedSources()
+	return nil
+}
+
+func (ba *SoluteBoard) Update() error {
+	values := ba.values
+	if err := ba.HumidityBoard.Update(); err != nil {
+		return err
+	}
+	// The solute follows the water in and out of each cell
+	ba.solute = ba.advect(values, ba.flux)
+	ba.feedSources()
+	return nil
+}
+
+func (ba *SoluteBoard) Paint(x, y int, m levels.Material) {
+	if w, h := ba.Size(); x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+	ba.HumidityBoard.Paint(x, y, m)
+	ba.solute[x][y] = 0
+	ba.feedSources()
+}
+
+// Click is redeclared so that painting goes through SoluteBoard.Paint.
+func (ba *SoluteBoard) Click(btn Button) {
+	paintClick(ba, ba.hvrX, ba.hvrY, ba.Rocks, btn)
+}
+
+// LoadSources makes every cell covered by a Nutrient entity hold its
+// amount of solute. It replaces the sources of a previous call.
+func (ba *SoluteBoard) LoadSources(entities []levels.Entity) error {
+	ba.sources = nil
+	for _, e := range entities {
+		if !strings.EqualFold(e.Identifier, NutrientEntity) {
+			continue
+		}
+		amount, err := sourceAmount(e.Properties["amount"])
+		if err != nil {
+			return fmt.Errorf("%s at %d, %d: %w", e.Identifier, e.X, e.Y, err)
+		}
+		// Point entities are smaller than a cell
+		w, h := e.Width, e.Height
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+		for x := e.X; x < e.X+w; x++ {
+			for y := e.Y; y < e.Y+h; y++ {
+				ba.sources = append(ba.sources, soluteSource{x, y, amount})
+			}
+		}
+	}
+	ba.feedSources()
+	return nil
+}
+
+func sourceAmount(v any) (float32, error) {
+	switch v := v.(type) {
+	case nil:
+		return DefaultSourceAmount, nil
+	case float64:
+		return checkAmount(v)
+	case int:
+		return checkAmount(float64(v))
+	case string:
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return 0, fmt.Errorf("amount %q is not a number", v)
+		}
+		return checkAmount(f)
+	}
+	return 0, fmt.Errorf("amount %v is not a number", v)
+}
+
+func checkAmount(v float64) (float32, error) {
+	if v < 0 || v > 1023 {
+		return 0, fmt.Errorf("amount %v must be within [0, 1023]", v)
+	}
+	return float32(v), nil
+}
+
+func (ba *SoluteBoard) feedSources() {
+	w, h := ba.Size()
+	for _, s := range ba.sources {
+		if s.x >= 0 && s.y >= 0 && s.x < w && s.y < h {
+			ba.solute[s.x][s.y] = s.amount
+		}
+	}
+}
+
+// Solute returns the amount of solute of each cell. It dissolves in the
+// humidity of the cell and moves with it.
+func (ba *SoluteBoard) Solute() [][]float32 {
+	return ba.solute
+}
+
+// concentration is the solute carried by each unit of humidity of a cell
+// leaving it, with the humidity values before the step. Cells past
+// Periodic and Mirror edges are the ones they wrap or reflect to, past
+// the other edges they carry none.
+func (ba *SoluteBoard) concentration(values [][]mgl32.Vec2, x, y int) float32 {
+	if e, past := ba.edgeOf(x, y); past {
+		var ok bool
+		if x, y, ok = ba.inside(e, x, y); !ok {
+			return 0
+		}
+	}
+	hum := values[x][y][0]
+	if hum <= 0 {
+		return 0
+	}
+	mobility := float32(1)
+	if ba.soil != nil {
+		mobility -= adsorption[ba.soil[x][y]]
+	}
+	return ba.solute[x][y] / hum * mobility
+}
+
+// advect moves the solute along the flux of the current step. Water taken
+// from a neighbour brings its solute, water given away takes some of the
+// cell's; like the water, the solute isn't exactly conserved.
+func (ba *SoluteBoard) advect(values [][]mgl32.Vec2, flux [][]mgl32.Vec4) [][]float32 {
+	s1 := make([][]float32, len(ba.solute))
+	for x, row := range ba.solute {
+		s1[x] = append([]float32{}, row...)
+		for y, s := range row {
+			c := ba.concentration(values, x, y)
+			in, out := float32(0), float32(0)
+			for i, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if f := flux[x][y][i]; f > 0 {
+					in += f * ba.concentration(values, n[0], n[1])
+				} else {
+					out -= f * c
+				}
+			}
+			if out > s {
+				out = s
+			}
+			s1[x][y] = s - out + in
+		}
+	}
+	return s1
+}
+
+// DrawSolute draws the solute in green, opaque from DefaultSourceAmount up.
+func (ba *SoluteBoard) DrawSolute(screen draw.Image) {
+	for x, row := range ba.solute {
+		for y, s := range row {
+			clr := soluteColor
+			clr.A = uint8(min32(s/DefaultSourceAmount, 1) * 255)
+			screen.Set(x, y, clr)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package boards
+
+import (
+	"testing"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+func TestLoadSources(t *testing.T) {
+	b := NewSoluteBoard([4]Boundary{})
+	b.Load(column(levels.Sand, 3))
+	err := b.LoadSources([]levels.Entity{
+		{Identifier: "nutrient", X: 0, Y: 2},
+		{Identifier: "Nutrient", X: 0, Y: 3, Width: 1, Height: 2, Properties: map[string]any{"amount": "200"}},
+		{Identifier: "Tree", X: 0, Y: 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float32{0, 0, DefaultSourceAmount, 200, 200}
+	for y, s := range b.Solute()[0] {
+		if s != want[y] {
+			t.Errorf("cell %d holds %f, want %f", y, s, want[y])
+		}
+	}
+
+	b.Paint(0, 2, levels.Air)
+	b.Reset()
+	if s := b.Solute()[0][2]; s != DefaultSourceAmount {
+		t.Errorf("sources should survive painting and reset, got %f", s)
+	}
+
+	for _, amount := range []any{"lots", "-5", 2000.0, -1} {
+		if err := b.LoadSources([]levels.Entity{{Identifier: "Nutrient", Properties: map[string]any{"amount": amount}}}); err == nil {
+			t.Errorf("expected an error for the amount %v", amount)
+		}
+	}
+}
+
+func TestSoluteCrossesPeriodicEdges(t *testing.T) {
+	// A wet row of sand with the source on its first cell: past the left
+	// edge the water and the solute come from the last one
+	b := NewSoluteBoard([4]Boundary{{Mode: Periodic}, {Mode: Periodic}, {}, {}})
+	b.Load([][]levels.Material{{levels.Sand}, {levels.Sand}, {levels.Sand}, {levels.Sand}, {levels.Sand}})
+	if err := b.LoadSources([]levels.Entity{{Identifier: NutrientEntity, X: 0, Y: 0}}); err != nil {
+		t.Fatal(err)
+	}
+	for x := range b.GetState() {
+		b.GetState()[x][0][0] = 300
+	}
+	b.GetState()[0][0][0] = 400
+	b.Update()
+	if s := b.Solute()[4][0]; s <= 0 {
+		t.Errorf("the last cell took no solute across the edge, got %f", s)
+	}
+}
+
+// spread is the solute gathered below a source over a column of m under
+// the rain.
+func spread(t *testing.T, m levels.Material) float32 {
+	b := NewSoluteBoard([4]Boundary{})
+	b.Load(column(m, 6))
+	if err := b.LoadSources([]levels.Entity{{Identifier: NutrientEntity, X: 0, Y: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		b.Update()
+	}
+	total := float32(0)
+	for _, s := range b.Solute()[0][3:] {
+		total += s
+	}
+	return total
+}
+
+func TestAdsorptionHoldsSolute(t *testing.T) {
+	// Clay is less permeable than sand too, so compare the adsorption of
+	// clay and sand on the same material
+	defer func(a float32) { adsorption[levels.LooseSoil] = a }(adsorption[levels.LooseSoil])
+	adsorption[levels.LooseSoil] = adsorption[levels.Sand]
+	sand := spread(t, levels.LooseSoil)
+	adsorption[levels.LooseSoil] = adsorption[levels.Clay]
+	clay := spread(t, levels.LooseSoil)
+	if sand == 0 || clay >= sand {
+		t.Errorf("solute should travel further with sand adsorption, got %f with sand and %f with clay", sand, clay)
+	}
+}
+
+func TestSoluteIsOptIn(t *testing.T) {
+	var b Simulation = &HumidityBoard{}
+	if _, ok := b.(SoluteCarrier); ok {
+		t.Error("the humidity rule shouldn't carry solute")
+	}
+	for _, b := range []Simulation{NewThermalBoard([4]Boundary{}), NewErosionBoard([4]Boundary{}, nil)} {
+		if _, ok := b.(SoluteCarrier); ok {
+			t.Errorf("%T shouldn't carry solute", b)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package boards
+
+import (
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+const (
+	// Temperatures drawn from blue to red, in °C
+	minDrawnTemp = -10
+	maxDrawnTemp = 40
+	// Temperature at which ThermalBoard.Evaporation applies in full
+	evaporationTemp = 40
+)
+
+// conductivity is the share of the temperature difference with a neighbour
+// a cell takes each tick. Between two cells the lowest one applies; with
+// four neighbours it must stay under 0.25 for the diffusion to be stable.
+var conductivity = map[levels.Material]float32{
+	levels.Empty:     0.05,
+	levels.Air:       0.05,
+	levels.LooseSoil: 0.08,
+	levels.HardSoil:  0.12,
+	levels.Sand:      0.1,
+	levels.Clay:      0.15,
+	levels.Rock:      0.2,
+	levels.Rain:      0.2,
+	levels.Mud:       0.15,
+}
+
+// ThermalBoard runs the humidity rule along with the soil temperature. Heat
+// diffuses by the conductivity of each material; air cells follow a day
+// and night cycle and rain cells stay cold. Warm cells lose humidity to
+// evaporation and wet cells below zero freeze, becoming impermeable until
+// they thaw.
+//
+// It must be set up with Load, since the temperature depends on the
+// materials.
+type ThermalBoard struct {
+	HumidityBoard
+	// DayLength is the number of ticks of a day
+	DayLength int
+	// The air swings around AirMean by AirSwing during the day, in °C
+	AirMean, AirSwing float32
+	RainTemperature   float32
+	// Evaporation is the share of humidity lost per tick at 40 °C
+	Evaporation float32
+	// FreezeHumidity is the humidity from which a cell can freeze
+	FreezeHumidity float32
+
+	temp, initTemp [][]float32
+	// frozen holds the impermeability of frozen cells before freezing, 0
+	// for the others
+	frozen [][]float32
+	tick   int
+}
+
+func NewThermalBoard(edges [4]Boundary) *ThermalBoard {
+	return &ThermalBoard{
+		HumidityBoard:   HumidityBoard{Edges: edges},
+		DayLength:       600,
+		AirMean:         8,
+		AirSwing:        12,
+		RainTemperature: 2,
+		Evaporation:     0.002,
+		FreezeHumidity:  64,
+	}
+}
+
+func (ba *ThermalBoard) Load(soil [][]levels.Material) {
+	ba.HumidityBoard.Load(soil)
+	ba.initTemp = make([][]float32, len(soil))
+	for x, row := range soil {
+		ba.initTemp[x] = make([]float32, len(row))
+		for y, m := range row {
+			ba.initTemp[x][y] = ba.cellTemperature(m)
+		}
+	}
+	ba.Reset()
+}
+
+// cellTemperature is the initial temperature of a material.
+func (ba *ThermalBoard) cellTemperature(m levels.Material) float32 {
+	if m == levels.Rain {
+		return ba.RainTemperature
+	}
+	return ba.AirMean
+}
+
+func (ba *ThermalBoard) Reset() error {
+	ba.HumidityBoard.Reset()
+	ba.temp = make([][]float32, len(ba.initTemp))
+	ba.frozen = make([][]float32, len(ba.initTemp))
+	for x, row := range ba.initTemp {
+		ba.temp[x] = append([]float32{}, row...)
+		ba.frozen[x] = make([]float32, len(row))
+	}
+	ba.tick = 0
+	return nil
+}
+
+func (ba *ThermalBoard) Paint(x, y int, m levels.Material) {
+	if w, h := ba.Size(); x < 0 || y < 0 || x >= w || y >= h {
+		return
+	}
+	ba.HumidityBoard.Paint(x, y, m)
+	ba.temp[x][y] = ba.cellTemperature(m)
+	ba.initTemp[x][y] = ba.temp[x][y]
+	ba.frozen[x][y] = 0
+}
+
+// Click is redeclared so that painting goes through ThermalBoard.Paint.
+func (ba *ThermalBoard) Click(btn Button) {
+	paintClick(ba, ba.hvrX, ba.hvrY, ba.Rocks, btn)
+}
+
+// AirTemperature is the temperature of the air at the current tick.
+func (ba *ThermalBoard) AirTemperature() float32 {
+	phase := 2 * math.Pi * float64(ba.tick) / float64(ba.DayLength)
+	return ba.AirMean + ba.AirSwing*float32(math.Sin(phase))
+}
+
+// Temperature returns the temperature of every cell, in °C.
+func (ba *ThermalBoard) Temperature() [][]float32 {
+	return ba.temp
+}
+
+func (ba *ThermalBoard) Frozen(x, y int) bool {
+	return ba.frozen[x][y] != 0
+}
+
+func (ba *ThermalBoard) Update() error {
+	if err := ba.HumidityBoard.Update(); err != nil {
+		return err
+	}
+	ba.tick++
+	air := ba.AirTemperature()
+
+	t0 := ba.temp
+	w, h := ba.Size()
+	t1 := make([][]float32, w)
+	for x := range t1 {
+		t1[x] = make([]float32, h)
+		for y := range t1[x] {
+			m := ba.soil[x][y]
+			switch m {
+			case levels.Empty, levels.Air:
+				t1[x][y] = air
+				continue
+			case levels.Rain:
+				t1[x][y] = ba.RainTemperature
+				continue
+			}
+
+			// The edges are insulated
+			t := t0[x][y]
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= w || n[1] >= h {
+					continue
+				}
+				k := conductivity[m]
+				if kn := conductivity[ba.soil[n[0]][n[1]]]; kn < k {
+					k = kn
+				}
+				t += k * (t0[n[0]][n[1]] - t0[x][y])
+			}
+			t1[x][y] = t
+		}
+	}
+	ba.temp = t1
+
+	for x, row := range ba.values {
+		for y := range row {
+			ba.couple(x, y)
+		}
+	}
+	return nil
+}
+
+// couple applies the temperature of a cell to its humidity.
+func (ba *ThermalBoard) couple(x, y int) {
+	v := &ba.values[x][y]
+	t := ba.temp[x][y]
+	if ba.Rain[x][y] || ba.Rocks[x][y] {
+		return
+	}
+
+	if ba.frozen[x][y] != 0 {
+		if t > 0 {
+			v[1] = ba.frozen[x][y]
+			ba.frozen[x][y] = 0
+		}
+		return
+	}
+	if t < 0 && v[0] >= ba.FreezeHumidity {
+		ba.frozen[x][y] = v[1]
+		v[1] = HighImpermeability
+		return
+	}
+	if t > 0 {
+		v[0] -= v[0] * ba.Evaporation * t / evaporationTemp
+	}
+}
+
+// DrawTemperature draws the temperature of each cell from blue, at -10 °C
+// and below, to red, at 40 °C and above. Frozen cells are white.
+func (ba *ThermalBoard) DrawTemperature(screen draw.Image) {
+	for x, row := range ba.temp {
+		for y, t := range row {
+			if ba.frozen[x][y] != 0 {
+				screen.Set(x, y, color.White)
+				continue
+			}
+			f := (t - minDrawnTemp) / (maxDrawnTemp - minDrawnTemp)
+			f = float32(math.Max(0, math.Min(1, float64(f))))
+			screen.Set(x, y, util.HSVColor{H: uint16((1 - f) * 240), S: 255, V: 255})
+		}
+	}
+}
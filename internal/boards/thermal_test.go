@@ -0,0 +1,121 @@
+package boards
+
+import (
+	"testing"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// column is a level one cell wide: air on top, then rain, then n cells of m.
+func column(m levels.Material, n int) [][]levels.Material {
+	soil := [][]levels.Material{{levels.Air, levels.Rain}}
+	for i := 0; i < n; i++ {
+		soil[0] = append(soil[0], m)
+	}
+	return soil
+}
+
+// noCapacity lets loose soil fill up to 1023 and hold no water, like the
+// cells of boards set up without materials.
+var noCapacity = map[levels.Material]Holding{levels.LooseSoil: {1, 0}}
+
+func TestRainCoolsTheSoil(t *testing.T) {
+	b := NewThermalBoard([4]Boundary{})
+	b.AirSwing = 0
+	b.Load(column(levels.Clay, 4))
+	for i := 0; i < 200; i++ {
+		b.Update()
+	}
+	temp := b.Temperature()[0]
+	if temp[1] != b.RainTemperature {
+		t.Errorf("rain is at %f, want %f", temp[1], b.RainTemperature)
+	}
+	for y := 2; y < len(temp); y++ {
+		if temp[y] >= b.AirMean || temp[y] < b.RainTemperature {
+			t.Errorf("cell %d is at %f, want between the rain and the air", y, temp[y])
+		}
+		if y > 2 && temp[y] < temp[y-1] {
+			t.Errorf("cell %d is colder than the one closer to the rain", y)
+		}
+	}
+}
+
+func TestFrozenCellsHoldWater(t *testing.T) {
+	run := func(freeze float32) *ThermalBoard {
+		b := NewThermalBoard([4]Boundary{})
+		b.Holding = noCapacity
+		b.AirMean, b.AirSwing, b.RainTemperature = -5, 0, -5
+		b.FreezeHumidity = freeze
+		b.Load(column(levels.LooseSoil, 6))
+		for i := 0; i < 500; i++ {
+			b.Update()
+		}
+		return b
+	}
+	b, thawed := run(64), run(1024)
+	if !b.Frozen(0, 2) {
+		t.Fatal("the wet cell under the rain should freeze")
+	}
+	if v := b.GetState()[0][2][1]; v < HighImpermeability {
+		t.Errorf("frozen cell has impermeability %f", v)
+	}
+	if got, want := b.GetState()[0][4][0], thawed.GetState()[0][4][0]; got >= want {
+		t.Errorf("ice should hold the water, the cell below it has %f, %f without ice", got, want)
+	}
+
+	b.Reset()
+	if b.Frozen(0, 2) {
+		t.Error("reset should thaw every cell")
+	}
+}
+
+func TestEvaporation(t *testing.T) {
+	dry := func(evaporation float32) float32 {
+		b := NewThermalBoard([4]Boundary{})
+		b.Holding = noCapacity
+		b.AirMean, b.AirSwing, b.RainTemperature = 30, 0, 30
+		b.Evaporation = evaporation
+		b.Load(column(levels.LooseSoil, 4))
+		for i := 0; i < 100; i++ {
+			b.Update()
+		}
+		return b.GetState()[0][5][0]
+	}
+	if wet, dried := dry(0), dry(0.01); dried >= wet {
+		t.Errorf("evaporation should dry the soil, got %f with and %f without", dried, wet)
+	}
+}
+
+// With its field capacity loose soil holds the water near the rain, so the
+// checks look at the cell under the ice and the water of the whole column.
+func TestThermalWithCapacity(t *testing.T) {
+	run := func(freeze, evaporation, temp float32, ticks int) *ThermalBoard {
+		b := NewThermalBoard([4]Boundary{})
+		b.AirMean, b.AirSwing, b.RainTemperature = temp, 0, temp
+		b.FreezeHumidity, b.Evaporation = freeze, evaporation
+		b.Load(column(levels.LooseSoil, 6))
+		for i := 0; i < ticks; i++ {
+			b.Update()
+		}
+		return b
+	}
+
+	b, thawed := run(64, 0, -5, 500), run(1024, 0, -5, 500)
+	if !b.Frozen(0, 2) {
+		t.Fatal("the wet cell under the rain should freeze")
+	}
+	if got, want := b.GetState()[0][3][0], thawed.GetState()[0][3][0]; got >= want {
+		t.Errorf("ice should hold the water, the cell below it has %f, %f without ice", got, want)
+	}
+
+	total := func(b *ThermalBoard) float32 {
+		sum := float32(0)
+		for _, v := range b.GetState()[0][2:] {
+			sum += v[0]
+		}
+		return sum
+	}
+	if wet, dried := total(run(1024, 0, 30, 100)), total(run(1024, 0.01, 30, 100)); dried >= wet {
+		t.Errorf("evaporation should dry the water held by the soil, got %f with and %f without", dried, wet)
+	}
+}
@@ -2,8 +2,17 @@ package boards
 
 import (
 	"image/color"
+	"image/draw"
 
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// Button is the mouse button of a board click.
+type Button int
+
+const (
+	LeftButton Button = iota
+	RightButton
 )
 
 type EnumBoard struct {
@@ -19,7 +28,7 @@ func (ba *EnumBoard) Update() error {
 	return nil
 }
 
-func (ba *EnumBoard) Draw(screen *ebiten.Image) {
+func (ba *EnumBoard) Draw(screen draw.Image) {
 	for x, row := range ba.values {
 		for y, v0 := range row {
 			screen.Set(x, y, v0)
@@ -43,7 +52,11 @@ func (ba *EnumBoard) GetState() [][]color.Color {
 	return ba.values
 }
 
-func (ba *EnumBoard) Click(btn ebiten.MouseButton) {
+func (ba *EnumBoard) SetState(values [][]color.Color) {
+	ba.values = values
+}
+
+func (ba *EnumBoard) Click(btn Button) {
 	return
 }
 
@@ -51,3 +64,15 @@ func (ba *EnumBoard) Hover(x, y int) {
 	ba.hvrX = x
 	ba.hvrY = y
 }
+
+// MakeColorGrid paints each material of a level with its preview colour.
+func MakeColorGrid(soil [][]levels.Material) [][]color.Color {
+	clrs := make([][]color.Color, len(soil))
+	for i := range clrs {
+		clrs[i] = make([]color.Color, len(soil[i]))
+		for j := range clrs[i] {
+			clrs[i][j] = soil[i][j].Color()
+		}
+	}
+	return clrs
+}
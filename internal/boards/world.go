@@ -0,0 +1,326 @@
+package boards
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// ChunkState is how much simulation a chunk gets, by its distance to the
+// focus of the world.
+type ChunkState int
+
+const (
+	// Active chunks step every tick
+	Active ChunkState = iota
+	// Slow chunks step once every World.SlowRate ticks
+	Slow
+	// Frozen chunks keep their board but don't step
+	Frozen
+	// Unloaded chunks only keep their cells, until streamed in again
+	Unloaded
+)
+
+// Chunk is one level of a World, simulated on its own HumidityBoard.
+type Chunk struct {
+	Level levels.Level
+	Board *HumidityBoard // nil while unloaded
+	State ChunkState
+	rect  image.Rectangle // in world cells
+	// soil holds the painted materials while unloaded
+	soil [][]levels.Material
+	// saved holds the cells while unloaded, nil if never simulated
+	saved [][]mgl32.Vec2
+	// prev is the board at the start of the tick, read by the neighbours
+	prev [][]mgl32.Vec2
+}
+
+// World stitches levels by their world position into one simulation.
+// Water crosses the seams between loaded chunks; past the edges of the
+// world the Edges boundaries apply. Periodic and Mirror edges wrap and
+// reflect around the whole world. No water crosses the seams towards gaps
+// and unloaded chunks, nor the edges that wrap onto them.
+//
+// Chunks are simulated according to their distance in cells to the focused
+// chunk: up to ActiveRange they are Active, up to SlowRange Slow, up to
+// LoadRange Frozen and beyond that Unloaded.
+type World struct {
+	Chunks []*Chunk
+	Focus  int
+	Edges  [4]Boundary
+
+	ActiveRange, SlowRange, LoadRange int
+	SlowRate                          int
+
+	bounds image.Rectangle
+	tick   int
+	hover  *Chunk
+}
+
+// NewWorld lays the levels out as chunks, with ranges sized for chunks as
+// large as the first level: its neighbours are active, the next ring is
+// slowed and the one after frozen.
+func NewWorld(lvls []levels.Level, edges [4]Boundary) (*World, error) {
+	if len(lvls) == 0 {
+		return nil, fmt.Errorf("a world needs at least one level")
+	}
+	w := &World{SlowRate: 4, Edges: edges}
+	for _, lvl := range lvls {
+		cw, ch := lvl.Size()
+		c := &Chunk{
+			Level: lvl,
+			State: Unloaded,
+			rect:  image.Rect(lvl.WorldX, lvl.WorldY, lvl.WorldX+cw, lvl.WorldY+ch),
+		}
+		for _, o := range w.Chunks {
+			if o.rect.Overlaps(c.rect) {
+				return nil, fmt.Errorf("levels %s and %s overlap", o.Level.Identifier, lvl.Identifier)
+			}
+		}
+		w.Chunks = append(w.Chunks, c)
+		w.bounds = w.bounds.Union(c.rect)
+	}
+
+	size := w.Chunks[0].rect.Dx()
+	if h := w.Chunks[0].rect.Dy(); h > size {
+		size = h
+	}
+	w.ActiveRange, w.SlowRange, w.LoadRange = 0, size, size*2
+	w.stream()
+	return w, nil
+}
+
+// distance is the gap in cells between two chunks, 0 when they touch.
+func distance(a, b image.Rectangle) int {
+	dx, dy := 0, 0
+	if a.Max.X < b.Min.X {
+		dx = b.Min.X - a.Max.X
+	} else if b.Max.X < a.Min.X {
+		dx = a.Min.X - b.Max.X
+	}
+	if a.Max.Y < b.Min.Y {
+		dy = b.Min.Y - a.Max.Y
+	} else if b.Max.Y < a.Min.Y {
+		dy = a.Min.Y - b.Max.Y
+	}
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
+
+// stream updates the state of every chunk, loading and unloading boards.
+func (w *World) stream() {
+	focus := w.Chunks[w.Focus].rect
+	for _, c := range w.Chunks {
+		d := distance(focus, c.rect)
+		state := Unloaded
+		switch {
+		case d <= w.ActiveRange:
+			state = Active
+		case d <= w.SlowRange:
+			state = Slow
+		case d <= w.LoadRange:
+			state = Frozen
+		}
+
+		if state == Unloaded && c.Board != nil {
+			c.saved, c.soil = c.Board.GetState(), c.Board.Materials()
+			c.Board, c.prev = nil, nil
+		} else if state != Unloaded && c.Board == nil {
+			w.load(c)
+		}
+		c.State = state
+	}
+}
+
+func (w *World) load(c *Chunk) {
+	if c.soil == nil {
+		c.soil = c.Level.Soil
+	}
+	// Only the sides on the edges of the world take its boundaries, the
+	// seams are closed when Outside has no cell. Chunks only wrap and
+	// reflect through Outside, within a chunk that would short the seams.
+	edges := [4]Boundary{}
+	onEdge := [4]bool{
+		LeftEdge:   c.rect.Min.X == w.bounds.Min.X,
+		RightEdge:  c.rect.Max.X == w.bounds.Max.X,
+		TopEdge:    c.rect.Min.Y == w.bounds.Min.Y,
+		BottomEdge: c.rect.Max.Y == w.bounds.Max.Y,
+	}
+	for i, e := range w.Edges {
+		if !onEdge[i] {
+			continue
+		}
+		edges[i] = e
+		if e.Mode == Periodic {
+			edges[i].Mode = Mirror
+		}
+	}
+	c.Board = &HumidityBoard{Edges: edges}
+	c.Board.Load(c.soil)
+	if c.saved != nil {
+		c.Board.SetState(c.saved)
+	}
+	c.Board.Hover(-1, -1)
+	c.Board.Outside = func(x, y int) (mgl32.Vec2, bool) {
+		if x, y, ok := w.outside(c.rect.Min.X+x, c.rect.Min.Y+y); ok {
+			return w.cellAt(x, y)
+		}
+		return mgl32.Vec2{}, false
+	}
+	c.Board.OutsideLimits = func(x, y int) (mgl32.Vec2, bool) {
+		if x, y, ok := w.outside(c.rect.Min.X+x, c.rect.Min.Y+y); ok {
+			return w.limitsAt(x, y)
+		}
+		return mgl32.Vec2{}, false
+	}
+	c.prev = c.Board.GetState()
+}
+
+// cellAt returns the cell of a loaded chunk at world coordinates, as it was
+// at the start of the tick.
+func (w *World) cellAt(x, y int) (mgl32.Vec2, bool) {
+	c := w.chunkAt(x, y)
+	if c == nil || c.prev == nil {
+		return mgl32.Vec2{}, false
+	}
+	return c.prev[x-c.rect.Min.X][y-c.rect.Min.Y], true
+}
+
+// limitsAt returns the [capacity, field capacity] of the cell of a loaded
+// chunk at world coordinates.
+func (w *World) limitsAt(x, y int) (mgl32.Vec2, bool) {
+	c := w.chunkAt(x, y)
+	if c == nil || c.Board == nil {
+		return mgl32.Vec2{}, false
+	}
+	return c.Board.limits(x-c.rect.Min.X, y-c.rect.Min.Y), true
+}
+
+// outside resolves a cell past the edge of a chunk, in world coordinates,
+// wrapping or reflecting it into the world past its Periodic and Mirror
+// edges. It reports false for the other edges, left to the boundaries of
+// the chunk, as are the cells of unloaded chunks.
+func (w *World) outside(x, y int) (int, int, bool) {
+	b := w.bounds
+	var e Edge
+	switch {
+	case x < b.Min.X:
+		e = LeftEdge
+	case x >= b.Max.X:
+		e = RightEdge
+	case y < b.Min.Y:
+		e = TopEdge
+	case y >= b.Max.Y:
+		e = BottomEdge
+	default:
+		return x, y, true
+	}
+
+	switch w.Edges[e].Mode {
+	case Periodic:
+		x = b.Min.X + (x-b.Min.X+b.Dx())%b.Dx()
+		y = b.Min.Y + (y-b.Min.Y+b.Dy())%b.Dy()
+	case Mirror:
+		x, y = clamp(x, b.Min.X, b.Max.X-1), clamp(y, b.Min.Y, b.Max.Y-1)
+	default:
+		return x, y, false
+	}
+	return x, y, true
+}
+
+func (w *World) chunkAt(x, y int) *Chunk {
+	p := image.Pt(x, y)
+	for _, c := range w.Chunks {
+		if p.In(c.rect) {
+			return c
+		}
+	}
+	return nil
+}
+
+// SetFocus moves the focus to the i-th chunk and streams chunks around it.
+func (w *World) SetFocus(i int) {
+	w.Focus = i
+	w.stream()
+}
+
+func (w *World) Update() error {
+	w.tick++
+	// Neighbours read the cells of the last tick, so the order in which
+	// the chunks step doesn't matter
+	for _, c := range w.Chunks {
+		if c.Board != nil {
+			c.prev = c.Board.GetState()
+		}
+	}
+	for _, c := range w.Chunks {
+		if c.State == Active || c.State == Slow && w.tick%w.SlowRate == 0 {
+			c.Board.Update()
+		}
+	}
+	return nil
+}
+
+// Bounds is the area covered by the chunks, in world cells.
+func (w *World) Bounds() image.Rectangle {
+	return w.bounds
+}
+
+func (w *World) Size() (int, int) {
+	return w.bounds.Dx(), w.bounds.Dy()
+}
+
+// offsetImage shifts drawing by an offset, so boards draw in place.
+type offsetImage struct {
+	draw.Image
+	dx, dy int
+}
+
+func (img offsetImage) Set(x, y int, c color.Color) {
+	img.Image.Set(x+img.dx, y+img.dy, c)
+}
+
+// Draw renders the loaded chunks with the top left of the world at 0, 0.
+// Unloaded chunks are left blank.
+func (w *World) Draw(screen draw.Image) {
+	for _, c := range w.Chunks {
+		if c.Board != nil {
+			c.Board.Draw(offsetImage{screen, c.rect.Min.X - w.bounds.Min.X, c.rect.Min.Y - w.bounds.Min.Y})
+		}
+	}
+}
+
+func (w *World) Reset() error {
+	for _, c := range w.Chunks {
+		c.saved = nil
+		if c.Board != nil {
+			c.Board.Reset()
+			c.prev = c.Board.GetState()
+		}
+	}
+	return nil
+}
+
+// Hover takes coordinates relative to the top left of the world.
+func (w *World) Hover(x, y int) {
+	if w.hover != nil && w.hover.Board != nil {
+		w.hover.Board.Hover(-1, -1)
+	}
+	w.hover = w.chunkAt(x+w.bounds.Min.X, y+w.bounds.Min.Y)
+	if w.hover == nil || w.hover.Board == nil {
+		return
+	}
+	w.hover.Board.Hover(x+w.bounds.Min.X-w.hover.rect.Min.X, y+w.bounds.Min.Y-w.hover.rect.Min.Y)
+}
+
+func (w *World) Click(btn Button) {
+	if w.hover != nil && w.hover.Board != nil {
+		w.hover.Board.Click(btn)
+	}
+}
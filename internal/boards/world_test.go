@@ -0,0 +1,140 @@
+package boards
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// strip lays n air levels of 4x4 cells side by side, with rain on the
+// left column of the first one.
+func strip(n int) []levels.Level {
+	lvls := []levels.Level{}
+	for i := 0; i < n; i++ {
+		soil := [][]levels.Material{}
+		for x := 0; x < 4; x++ {
+			soil = append(soil, []levels.Material{levels.Air, levels.Air, levels.Air, levels.Air})
+		}
+		if i == 0 {
+			soil[0] = []levels.Material{levels.Rain, levels.Rain, levels.Rain, levels.Rain}
+		}
+		lvls = append(lvls, levels.Level{Identifier: fmt.Sprint("Level_", i), Soil: soil, WorldX: i * 4})
+	}
+	return lvls
+}
+
+func TestWorldFlowsAcrossSeams(t *testing.T) {
+	w, err := NewWorld(strip(2), [4]Boundary{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		w.Update()
+	}
+	if hum := w.Chunks[1].Board.GetState()[0][0][0]; hum <= 0 {
+		t.Errorf("water didn't reach the second chunk, humidity %v", hum)
+	}
+}
+
+func TestWorldStreaming(t *testing.T) {
+	w, err := NewWorld(strip(5), [4]Boundary{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChunkState{Active, Active, Slow, Frozen, Unloaded}
+	for i, c := range w.Chunks {
+		if c.State != want[i] {
+			t.Errorf("chunk %d is %v, want %v", i, c.State, want[i])
+		}
+	}
+	if w.Chunks[4].Board != nil {
+		t.Error("unloaded chunk kept its board")
+	}
+
+	for i := 0; i < 50; i++ {
+		w.Update()
+	}
+	wet := w.Chunks[0].Board.GetState()[1][1]
+
+	// Moving away unloads the first chunk, moving back restores its cells
+	w.SetFocus(4)
+	if w.Chunks[0].State != Unloaded || w.Chunks[0].Board != nil {
+		t.Fatalf("first chunk is still loaded as %v", w.Chunks[0].State)
+	}
+	w.SetFocus(0)
+	if got := w.Chunks[0].Board.GetState()[1][1]; got != wet {
+		t.Errorf("streamed in cell is %v, want %v", got, wet)
+	}
+}
+
+func TestWorldOverlap(t *testing.T) {
+	lvls := strip(2)
+	lvls[1].WorldX = 2
+	if _, err := NewWorld(lvls, [4]Boundary{}); err == nil {
+		t.Error("expected an error for overlapping levels")
+	}
+}
+
+func TestWorldSeamsToUnloadedChunksAreClosed(t *testing.T) {
+	fixed := Boundary{Mode: Fixed, Value: 1000}
+	w, err := NewWorld(strip(5), [4]Boundary{fixed, fixed, fixed, fixed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frozen := w.Chunks[3].Board
+	if w.Chunks[4].Board != nil || frozen == nil {
+		t.Fatal("the last chunk should be unloaded and the one before loaded")
+	}
+	closed := mgl32.Vec2{0, math.MaxFloat32}
+	if v := frozen.neighbour(4, 1); v != closed {
+		t.Errorf("the seam towards the unloaded chunk gives %v, want a closed edge", v)
+	}
+	if v := frozen.neighbour(1, -1); v != (mgl32.Vec2{1000, 1}) {
+		t.Errorf("above the world is %v, want the fixed edge", v)
+	}
+	if v := w.Chunks[0].Board.neighbour(-1, 1); v != (mgl32.Vec2{1000, 1}) {
+		t.Errorf("left of the world is %v, want the fixed edge", v)
+	}
+}
+
+func TestWorldEdgesWrapTheWorld(t *testing.T) {
+	w, err := NewWorld(strip(3), [4]Boundary{{Mode: Periodic}, {Mode: Periodic}, {Mode: Mirror}, {Mode: Mirror}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, last := w.Chunks[0].Board, w.Chunks[2].Board
+	last.Paint(3, 2, levels.Clay)
+	last.GetState()[3][2][0] = 500
+	first.GetState()[1][0][0] = 300
+
+	// Left of the world is its right column, not the right of the chunk
+	if v := first.neighbour(-1, 2); v != last.GetState()[3][2] {
+		t.Errorf("left of the world is %v, want the last column %v", v, last.GetState()[3][2])
+	}
+	if v := last.neighbour(4, 2); v != first.GetState()[0][2] {
+		t.Errorf("right of the world is %v, want the first column %v", v, first.GetState()[0][2])
+	}
+	if c := first.limits(-1, 2); c != first.soilHolding(levels.Clay) {
+		t.Errorf("left of the world holds %v, want the clay of the last column", c)
+	}
+	// Above the world is the top row itself
+	if v := first.neighbour(1, -1); v != first.GetState()[1][0] {
+		t.Errorf("above the world is %v, want the top row %v", v, first.GetState()[1][0])
+	}
+
+	// With the last chunk unloaded the wrap finds nothing and water stays
+	w.SlowRange, w.LoadRange = 0, 0
+	w.stream()
+	if w.Chunks[2].Board != nil {
+		t.Fatal("the last chunk should be unloaded")
+	}
+	if v := first.neighbour(-1, 2); v != first.GetState()[0][2] {
+		t.Errorf("wrapping to an unloaded chunk gave %v, want the edge cell %v", v, first.GetState()[0][2])
+	}
+	if c := first.limits(-1, 2); c != first.limits(0, 2) {
+		t.Errorf("wrapping to an unloaded chunk holds %v, want the edge cell", c)
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	minZoom = 0.25
+	maxZoom = 32
+	// Zoom factor of one wheel notch
+	zoomStep = 1.1
+	// Screen pixels moved per tick by the arrow keys
+	panSpeed = 8
+)
+
+// Camera maps the board to the screen. The board is first scaled to fit
+// the view, then by Zoom, and moved by Pan screen pixels.
+type Camera struct {
+	Zoom     float64
+	Pan      [2]float64
+	fit      float64
+	origin   [2]float64
+	dragging bool
+	dragFrom [2]int
+}
+
+func (c *Camera) scale() float64 {
+	if c.Zoom == 0 {
+		c.Zoom = 1
+	}
+	return c.fit * c.Zoom
+}
+
+// SetView places the view at origin on the screen, with fit the scale at
+// which the board fills it without zoom.
+func (c *Camera) SetView(originX, originY, fit float64) {
+	c.origin = [2]float64{originX, originY}
+	c.fit = fit
+}
+
+// GeoM transforms board pixels to screen pixels.
+func (c *Camera) GeoM() ebiten.GeoM {
+	g := ebiten.GeoM{}
+	g.Scale(c.scale(), c.scale())
+	g.Translate(c.origin[0]+c.Pan[0], c.origin[1]+c.Pan[1])
+	return g
+}
+
+// ToBoard converts a screen position to board pixels with the inverse
+// transform. It fails until the view is set.
+func (c *Camera) ToBoard(sx, sy int) (float64, float64, bool) {
+	if c.scale() == 0 {
+		return 0, 0, false
+	}
+	g := c.GeoM()
+	g.Invert()
+	x, y := g.Apply(float64(sx), float64(sy))
+	return x, y, true
+}
+
+// ToCell returns the board cell under a screen position.
+func (c *Camera) ToCell(sx, sy int) (int, int, bool) {
+	x, y, ok := c.ToBoard(sx, sy)
+	return int(math.Floor(x)), int(math.Floor(y)), ok
+}
+
+func (c *Camera) Reset() {
+	c.Zoom, c.Pan = 1, [2]float64{}
+}
+
+// Update zooms around the cursor with the wheel, pans with a middle button
+// drag or the arrow keys and resets on Home. The cursor is ignored while
+// over the toolbar.
+func (c *Camera) Update(cx, cy int, overMenu bool) {
+	if _, dy := ebiten.Wheel(); dy != 0 && !overMenu {
+		// Keep the board point under the cursor in place
+		bx, by, ok := c.ToBoard(cx, cy)
+		if !ok {
+			return
+		}
+		c.Zoom = math.Max(minZoom, math.Min(maxZoom, c.Zoom*math.Pow(zoomStep, dy)))
+		c.Pan[0] = float64(cx) - c.origin[0] - bx*c.scale()
+		c.Pan[1] = float64(cy) - c.origin[1] - by*c.scale()
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonMiddle) && !overMenu {
+		c.dragging = true
+	} else if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) {
+		c.dragging = false
+	}
+	if c.dragging {
+		c.Pan[0] += float64(cx - c.dragFrom[0])
+		c.Pan[1] += float64(cy - c.dragFrom[1])
+	}
+	c.dragFrom = [2]int{cx, cy}
+
+	keys := map[ebiten.Key][2]float64{
+		ebiten.KeyArrowLeft:  {panSpeed, 0},
+		ebiten.KeyArrowRight: {-panSpeed, 0},
+		ebiten.KeyArrowUp:    {0, panSpeed},
+		ebiten.KeyArrowDown:  {0, -panSpeed},
+	}
+	for k, d := range keys {
+		if ebiten.IsKeyPressed(k) {
+			c.Pan[0] += d[0]
+			c.Pan[1] += d[1]
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyHome) {
+		c.Reset()
+	}
+}
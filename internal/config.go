@@ -1,13 +1,20 @@
 package internal
 
 import (
-	"bytes"
 	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"image"
 	_ "image/png"
-	"log"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
 )
 
 var (
@@ -15,86 +22,199 @@ var (
 	assets embed.FS
 )
 
+const manifestFile = "manifest.json"
+
+// Sprite is an image holding Frames square frames laid out horizontally.
+type Sprite struct {
+	Image  *ebiten.Image
+	Frames int
+}
+
+// spriteDef describes one toolbar sprite. Aseprite sources are flattened
+// into a strip: one frame per layer when Layers is set, otherwise one per
+// animation frame, optionally limited to the frames of Tag.
+type spriteDef struct {
+	Path   string `json:"path"`
+	Frames int    `json:"frames"`
+	Layers bool   `json:"layers,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+}
+
+type manifest struct {
+	BtnSize int                  `json:"btnSize"`
+	Sprites map[string]spriteDef `json:"sprites"`
+}
+
+// ThemeError is returned by NewConf when a theme directory could not be
+// fully applied. The Config returned with it is still usable: every sprite
+// that failed to load falls back to the embedded one.
+type ThemeError struct {
+	Dir string
+	Err error
+}
+
+func (e *ThemeError) Error() string {
+	return fmt.Sprintf("theme %s: %s", e.Dir, e.Err)
+}
+
+func (e *ThemeError) Unwrap() error {
+	return e.Err
+}
+
 type Config struct {
-	btnSize  int
-	playBtn  *ebiten.Image
-	pauseBtn *ebiten.Image
-	resetBtn *ebiten.Image
-	speedBtn *ebiten.Image
-	treeBtn  *ebiten.Image
-	sceneBtn *ebiten.Image
+	btnSize int
+	sprites map[string]Sprite
+	trees   *util.TileMap
 }
 
-func NewConf() Config {
-	Conf := Config{btnSize: 16}
-	// Load Play Button
-	buf, err := assets.ReadFile("assets/play-btn.png")
+// NewConf loads the sprites described by the embedded manifest. If themeDir
+// is not empty, sprites found there override the embedded ones; it may hold
+// its own manifest.json to remap paths, otherwise the embedded paths are used.
+func NewConf(themeDir string) (Config, error) {
+	base, err := fs.Sub(assets, "assets")
 	if err != nil {
-		log.Fatal(err)
+		return Config{}, err
 	}
-	img, _, err := image.Decode(bytes.NewReader(buf))
+	man, err := readManifest(base)
 	if err != nil {
-		log.Fatal(err)
+		return Config{}, err
 	}
-	Conf.playBtn = ebiten.NewImageFromImage(img)
 
-	// Load Pause Button
-	buf, err = assets.ReadFile("assets/pause-btn.png")
-	if err != nil {
-		log.Fatal(err)
+	conf := Config{btnSize: man.BtnSize, sprites: map[string]Sprite{}}
+	for name, def := range man.Sprites {
+		sprite, err := conf.loadSprite(base, def)
+		if err != nil {
+			return Config{}, fmt.Errorf("sprite %q: %w", name, err)
+		}
+		conf.sprites[name] = sprite
 	}
-	img, _, err = image.Decode(bytes.NewReader(buf))
-	if err != nil {
-		log.Fatal(err)
+
+	if themeDir == "" {
+		return conf, nil
 	}
-	Conf.pauseBtn = ebiten.NewImageFromImage(img)
 
-	// Load Reset Button
-	buf, err = assets.ReadFile("assets/reset-btn.png")
-	if err != nil {
-		log.Fatal(err)
+	var errs []error
+	theme := os.DirFS(themeDir)
+	themeMan, err := readManifest(theme)
+	hasManifest := err == nil
+	if !hasManifest {
+		if !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		themeMan = man
 	}
-	img, _, err = image.Decode(bytes.NewReader(buf))
-	if err != nil {
-		log.Fatal(err)
+	// The toolbar lays every button out on the base grid
+	if hasManifest && themeMan.BtnSize != 0 && themeMan.BtnSize != man.BtnSize {
+		err := fmt.Errorf("%s: btnSize %d differs from the %d of the embedded buttons", manifestFile, themeMan.BtnSize, man.BtnSize)
+		return conf, &ThemeError{Dir: themeDir, Err: err}
 	}
-	Conf.resetBtn = ebiten.NewImageFromImage(img)
 
-	// Load Speed Button
-	buf, err = assets.ReadFile("assets/speed-btn.png")
-	if err != nil {
-		log.Fatal(err)
+	for name, def := range themeMan.Sprites {
+		baseDef, ok := man.Sprites[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown sprite %q", name))
+			continue
+		}
+		if def.Frames == 0 {
+			def.Frames = baseDef.Frames
+		}
+		sprite, err := conf.loadSprite(theme, def)
+		if err != nil {
+			// Without a manifest the theme only overrides the files it has
+			if hasManifest || !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("sprite %q: %w", name, err))
+			}
+			continue
+		}
+		conf.sprites[name] = sprite
 	}
-	img, _, err = image.Decode(bytes.NewReader(buf))
+
+	if len(errs) > 0 {
+		return conf, &ThemeError{Dir: themeDir, Err: errors.Join(errs...)}
+	}
+	return conf, nil
+}
+
+func (c Config) Sprite(name string) Sprite {
+	return c.sprites[name]
+}
+
+// LoadTrees reads the tree tileset referenced by the level source, from
+// either its Aseprite source or an exported image.
+func (c *Config) LoadTrees(ts levels.Tileset) error {
+	dir, name := filepath.Split(ts.Path)
+	if dir == "" {
+		dir = "."
+	}
+	img, err := decodeImage(os.DirFS(dir), name, spriteDef{})
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("tileset %q: %w", ts.Identifier, err)
 	}
+	b := img.Bounds()
+	c.trees = util.NewTileMap(img, uint(b.Dx()/ts.GridSize), uint(b.Dy()/ts.GridSize))
+	return nil
+}
 
-	Conf.speedBtn = ebiten.NewImageFromImage(img)
+func (c Config) Trees() *util.TileMap {
+	return c.trees
+}
 
-	// Load Tree Button
-	buf, err = assets.ReadFile("assets/tree-btn.png")
+func readManifest(fsys fs.FS) (manifest, error) {
+	man := manifest{}
+	buf, err := fs.ReadFile(fsys, manifestFile)
 	if err != nil {
-		log.Fatal(err)
+		return man, err
 	}
-	img, _, err = image.Decode(bytes.NewReader(buf))
+	if err := json.Unmarshal(buf, &man); err != nil {
+		return man, fmt.Errorf("%s: %w", manifestFile, err)
+	}
+	return man, nil
+}
+
+func decodeImage(fsys fs.FS, name string, def spriteDef) (image.Image, error) {
+	f, err := fsys.Open(path.Clean(name))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	defer f.Close()
 
-	Conf.treeBtn = ebiten.NewImageFromImage(img)
+	if path.Ext(name) != ".aseprite" {
+		img, _, err := image.Decode(f)
+		return img, err
+	}
 
-	// Load Scene Button
-	buf, err = assets.ReadFile("assets/scene-btn.png")
+	ase, err := util.DecodeAseprite(f)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	var strip *image.NRGBA
+	switch {
+	case def.Layers:
+		strip, err = ase.LayerStrip(0)
+	case def.Tag != "":
+		tag, ok := ase.Tag(def.Tag)
+		if !ok {
+			return nil, fmt.Errorf("no tag named %q", def.Tag)
+		}
+		strip, err = ase.FrameStrip(tag.From, tag.To)
+	default:
+		strip, err = ase.FrameStrip(0, len(ase.Frames)-1)
 	}
-	img, _, err = image.Decode(bytes.NewReader(buf))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	return strip, nil
+}
 
-	Conf.sceneBtn = ebiten.NewImageFromImage(img)
+func (c Config) loadSprite(fsys fs.FS, def spriteDef) (Sprite, error) {
+	img, err := decodeImage(fsys, def.Path, def)
+	if err != nil {
+		return Sprite{}, fmt.Errorf("%s: %w", def.Path, err)
+	}
 
-	return Conf
+	// Frames must match the button grid or the toolbar would draw garbage
+	if b := img.Bounds(); b.Dy() != c.btnSize || b.Dx() != def.Frames*c.btnSize {
+		return Sprite{}, fmt.Errorf("%s: expected %dx%d image, got %dx%d", def.Path, def.Frames*c.btnSize, c.btnSize, b.Dx(), b.Dy())
+	}
+	return Sprite{Image: ebiten.NewImageFromImage(img), Frames: def.Frames}, nil
 }
@@ -0,0 +1,207 @@
+package internal
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/joelschutz/soil-demo/internal/boards"
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/util"
+)
+
+// Layers of the board view, from bottom to top.
+const (
+	LayerMaterials = iota
+	LayerHumidity
+	LayerTemperature
+	LayerSolute
+	LayerGrid
+	LayerFlux
+	LayerTrees
+	layerCount
+)
+
+const (
+	// Pixels per cell of the grid and tree layers when there is no tileset
+	detailSize = 16
+	// Ticks the layer list stays on screen after a layer key
+	layerHUDTicks = 120
+	// Flows below this fraction of the strongest one get no arrow
+	minFlow = 0.02
+)
+
+var (
+	gridColor = color.RGBA{0, 0, 0, 0x60}
+	flowColor = color.RGBA{0, 0, 0, 0xff}
+)
+
+// Layer is one image of the board view. Hidden, rather than visible, so
+// that all layers show by default.
+type Layer struct {
+	Name    string
+	Hidden  bool
+	Opacity float32
+}
+
+// Layers holds the view settings shared by every board on screen.
+type Layers struct {
+	List     [layerCount]Layer
+	selected int
+	hud      int
+}
+
+func NewLayers() Layers {
+	return Layers{List: [layerCount]Layer{
+		LayerMaterials:   {Name: "Materials", Opacity: 1},
+		LayerHumidity:    {Name: "Humidity", Opacity: 0.8},
+		LayerTemperature: {Name: "Heat", Opacity: 0.6, Hidden: true},
+		LayerSolute:      {Name: "Nutrients", Opacity: 1, Hidden: true},
+		LayerGrid:        {Name: "Grid", Opacity: 1, Hidden: true},
+		LayerFlux:        {Name: "Flux", Opacity: 1, Hidden: true},
+		LayerTrees:       {Name: "Trees", Opacity: 1},
+	}}
+}
+
+// Update toggles a layer with its number key, selects one with Tab and
+// changes the opacity of the selected layer with [ and ].
+func (l *Layers) Update() {
+	if l.hud > 0 {
+		l.hud--
+	}
+	for i := range l.List {
+		if inpututil.IsKeyJustPressed(ebiten.Key1 + ebiten.Key(i)) {
+			l.List[i].Hidden = !l.List[i].Hidden
+			l.selected, l.hud = i, layerHUDTicks
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+		l.selected = (l.selected + 1) % len(l.List)
+		l.hud = layerHUDTicks
+	}
+	step := float32(0)
+	if inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		step = -0.1
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		step = 0.1
+	}
+	if step != 0 {
+		layer := &l.List[l.selected]
+		layer.Opacity = float32(math.Round(float64(layer.Opacity+step)*10) / 10)
+		layer.Opacity = float32(math.Max(0, math.Min(1, float64(layer.Opacity))))
+		l.hud = layerHUDTicks
+	}
+}
+
+// DrawHUD lists the layers in the bottom left corner for a while after
+// they change.
+func (l *Layers) DrawHUD(screen *ebiten.Image, x int) {
+	if l.hud == 0 {
+		return
+	}
+	lines := []string{}
+	for i, layer := range l.List {
+		mark, state := " ", "on "
+		if i == l.selected {
+			mark = ">"
+		}
+		if layer.Hidden {
+			state = "off"
+		}
+		lines = append(lines, fmt.Sprintf("%s%d %-9s %s %3.0f%%", mark, i+1, layer.Name, state, layer.Opacity*100))
+	}
+	// DebugPrint uses 16 pixel lines
+	ebitenutil.DebugPrintAt(screen, strings.Join(lines, "\n"), x+4, screen.Bounds().Dy()-16*len(lines)-4)
+}
+
+// Draw draws the visible layers with geo mapping cells to the screen. The
+// images map one pixel per cell, or size pixels per cell from the grid up.
+func (l *Layers) Draw(screen *ebiten.Image, geo ebiten.GeoM, imgs [layerCount]*ebiten.Image, size int) {
+	for i, layer := range l.List {
+		if layer.Hidden || imgs[i] == nil {
+			continue
+		}
+		op := &ebiten.DrawImageOptions{}
+		if i >= LayerGrid {
+			op.GeoM.Scale(1/float64(size), 1/float64(size))
+		}
+		op.GeoM.Concat(geo)
+		op.ColorScale.ScaleAlpha(layer.Opacity)
+		screen.DrawImage(imgs[i], op)
+	}
+}
+
+// GridImage draws the cell borders of a w by h board, size pixels per cell.
+func GridImage(w, h, size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w*size+1, h*size+1))
+	// A square only draws its top and left lines, the extra row and column
+	// close the bottom and right edges
+	cell := &util.LineSquare{Size: image.Rect(0, 0, size, size), Clr: gridColor}
+	for x := 0; x <= w; x++ {
+		for y := 0; y <= h; y++ {
+			r := image.Rect(x*size, y*size, (x+1)*size, (y+1)*size)
+			draw.Draw(img, r, cell, image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+// TreeImage draws the tiles of a level from the tree tileset, size pixels
+// per cell.
+func TreeImage(lvl levels.Level, trees *util.TileMap, size int) *image.RGBA {
+	w, h := lvl.Size()
+	img := image.NewRGBA(image.Rect(0, 0, w*size, h*size))
+	for _, t := range lvl.Tiles {
+		if t.Tileset != "Trees" {
+			continue
+		}
+		tile := trees.GetTile(uint(t.ID))
+		r := image.Rect(t.X*size, t.Y*size, (t.X+1)*size, (t.Y+1)*size)
+		draw.Draw(img, r, tile, tile.Bounds().Min, draw.Over)
+	}
+	return img
+}
+
+// DrawFlow draws an arrow on each cell of a w by h board along its flow,
+// size pixels per cell. Lengths are relative to the strongest flow,
+// which spans most of a cell.
+func DrawFlow(dst *ebiten.Image, board boards.Flow, w, h, size int) {
+	top := float32(0)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			if l := board.FlowAt(x, y).Len(); l > top {
+				top = l
+			}
+		}
+	}
+	if top == 0 {
+		return
+	}
+
+	half := float32(size) * 0.45
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f := board.FlowAt(x, y).Mul(1 / top)
+			if f.Len() < minFlow {
+				continue
+			}
+			c := mgl32.Vec2{(float32(x) + 0.5) * float32(size), (float32(y) + 0.5) * float32(size)}
+			tail, tip := c.Sub(f.Mul(half)), c.Add(f.Mul(half))
+			vector.StrokeLine(dst, tail[0], tail[1], tip[0], tip[1], 1, flowColor, true)
+			// Head lines a third of the arrow long, 30 degrees off its back
+			back := f.Normalize().Mul(-f.Len() * half * 2 / 3)
+			for _, a := range []float32{math.Pi / 6, -math.Pi / 6} {
+				end := tip.Add(mgl32.Rotate2D(a).Mul2x1(back))
+				vector.StrokeLine(dst, tip[0], tip[1], end[0], end[1], 1, flowColor, true)
+			}
+		}
+	}
+}
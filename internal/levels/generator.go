@@ -0,0 +1,99 @@
+package levels
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/joelschutz/soil-demo/util"
+)
+
+// GeneratorSource builds soil profiles from seeded noise. From top to
+// bottom every level has a row of clouds, air, a loose topsoil band and
+// hard soil crossed by clay strata, with sand lenses and rock outcrops.
+// The levels are laid side by side in the world.
+type GeneratorSource struct {
+	Seed          int64
+	Count         int
+	Width, Height int
+}
+
+func (g *GeneratorSource) Levels() ([]Level, error) {
+	if g.Width < 4 || g.Height < 8 {
+		return nil, fmt.Errorf("generated levels must be at least 4x8, got %dx%d", g.Width, g.Height)
+	}
+	rng := rand.New(rand.NewSource(g.Seed))
+	lvls := []Level{}
+	for i := 0; i < g.Count; i++ {
+		lvls = append(lvls, Level{
+			Identifier: fmt.Sprintf("Generated_%d_%d", g.Seed, i),
+			Soil:       Generate(rng, g.Width, g.Height),
+			Metadata:   map[string]any{"seed": g.Seed, "index": i},
+			WorldX:     i * g.Width,
+		})
+	}
+	return lvls, nil
+}
+
+// Generate creates one soil profile, consuming rng for its noise fields.
+func Generate(rng *rand.Rand, w, h int) [][]Material {
+	clouds := util.NewNoise(rng)
+	surface := util.NewNoise(rng)
+	strata := util.NewNoise(rng)
+	lenses := util.NewNoise(rng)
+	rocks := util.NewNoise(rng)
+
+	soil := newSoil(w, h)
+	fh := float64(h)
+	for x := 0; x < w; x++ {
+		fx := float64(x)
+
+		// Cloud row, broken in a few clusters
+		if clouds.Fractal(fx/4, 0.5, 2) > -0.1 {
+			soil[x][0] = Rain
+		}
+
+		// Gently rolling ground, starting about a quarter of the way down
+		top := int(fh*0.3 + surface.Fractal(fx/8, 0.5, 3)*fh*0.12)
+		top = clamp(top, 2, h-4)
+		topsoil := top + 2 + int((surface.At(fx/3, 7.5)+1)*1.5)
+		// Clay strata follow a wavy line in the lower half
+		clay := int(fh*0.65 + strata.Fractal(fx/6, 3.5, 2)*fh*0.1)
+
+		for y := top; y < h; y++ {
+			fy := float64(y)
+			m := HardSoil
+			switch {
+			case y < topsoil:
+				m = LooseSoil
+			case y == clay || y == clay+1 && strata.At(fx/2, fy) > 0:
+				m = Clay
+			case lenses.Fractal(fx/6, fy/2, 2) > 0.3:
+				// Stretched sideways so the blobs look like lenses
+				m = Sand
+			}
+
+			// Outcrops get more frequent with depth and may pierce the surface
+			depth := (fy - float64(top)) / fh
+			if rocks.Fractal(fx/4, fy/4, 2)+depth*0.3 > 0.45 {
+				m = Rock
+			}
+			soil[x][y] = m
+		}
+
+		// Tall outcrops stick out of the ground
+		if rocks.Fractal(fx/4, float64(top)/4, 2) > 0.35 && top > 2 {
+			soil[x][top-1] = Rock
+		}
+	}
+	return soil
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,50 @@
+package levels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGeneratorDeterminism(t *testing.T) {
+	gen := func(seed int64) []Level {
+		t.Helper()
+		lvls, err := (&GeneratorSource{Seed: seed, Count: 3, Width: 16, Height: 16}).Levels()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lvls
+	}
+
+	a, b := gen(42), gen(42)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("the same seed yielded different levels")
+	}
+	if reflect.DeepEqual(a[0].Soil, a[1].Soil) {
+		t.Error("levels of one seed should differ from each other")
+	}
+	if reflect.DeepEqual(a[0].Soil, gen(43)[0].Soil) {
+		t.Error("another seed yielded the same level")
+	}
+
+	for i, lvl := range a {
+		if w, h := lvl.Size(); w != 16 || h != 16 {
+			t.Errorf("level %d is %dx%d, want 16x16", i, w, h)
+		}
+		if lvl.WorldX != i*16 {
+			t.Errorf("level %d is at x %d, want %d", i, lvl.WorldX, i*16)
+		}
+		for x := range lvl.Soil {
+			for y, m := range lvl.Soil[x] {
+				if m < Air || m > Rain || m == Rain && y != 0 {
+					t.Fatalf("level %d has %v at (%d, %d)", i, m, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestGeneratorSize(t *testing.T) {
+	if _, err := (&GeneratorSource{Count: 1, Width: 3, Height: 16}).Levels(); err == nil {
+		t.Error("levels narrower than 4 cells should be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package levels
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelschutz/soil-demo/util"
+)
+
+// ImageSource reads one level from each image, one cell per pixel. Pixels
+// are matched to the material with the closest Palette colour, so maps can
+// be sketched in any paint program. Transparent pixels are air.
+type ImageSource struct {
+	Paths []string
+}
+
+func (s *ImageSource) Levels() ([]Level, error) {
+	lvls := []Level{}
+	wx := 0
+	for _, path := range s.Paths {
+		lvl, err := OpenImage(path)
+		if err != nil {
+			return nil, err
+		}
+		// One file after the other, left to right
+		lvl.WorldX = wx
+		wx += len(lvl.Soil)
+		lvls = append(lvls, lvl)
+	}
+	return lvls, nil
+}
+
+func OpenImage(path string) (Level, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Level{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return Level{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return Level{
+		Identifier: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Soil:       FromImage(img),
+	}, nil
+}
+
+// FromImage converts every pixel to its nearest material.
+func FromImage(img image.Image) [][]Material {
+	rgba := util.AsRGBA(img)
+	b := rgba.Bounds()
+	soil := newSoil(b.Dx(), b.Dy())
+	cache := map[color.RGBA]Material{}
+	for x := range soil {
+		for y := range soil[x] {
+			clr := rgba.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			if clr.A < 0x80 {
+				continue
+			}
+			clr.A = 0xff
+			m, ok := cache[clr]
+			if !ok {
+				m = NearestMaterial(clr)
+				cache[clr] = m
+			}
+			soil[x][y] = m
+		}
+	}
+	return soil
+}
+
+// NearestMaterial returns the material whose Palette colour is the closest
+// to clr, by euclidean distance in RGB.
+func NearestMaterial(clr color.Color) Material {
+	r, g, b, _ := clr.RGBA()
+	best, bestDist := Air, -1
+	for m := Air; m <= Rain; m++ {
+		p := Palette[m]
+		dr := int(r>>8) - int(p.R)
+		dg := int(g>>8) - int(p.G)
+		db := int(b>>8) - int(p.B)
+		if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+			best, bestDist = m, d
+		}
+	}
+	return best
+}
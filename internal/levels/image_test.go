@@ -0,0 +1,53 @@
+package levels
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	// An exact palette colour, two close ones and a transparent pixel
+	img.SetNRGBA(0, 0, color.NRGBA{0xEA, 0xD4, 0xAA, 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{0xB0, 0x50, 0x30, 0xff})
+	img.SetNRGBA(0, 1, color.NRGBA{0x10, 0x50, 0x90, 0xc0})
+	img.SetNRGBA(1, 1, color.NRGBA{0x5A, 0x69, 0x88, 0x10})
+
+	path := filepath.Join(t.TempDir(), "sketch.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	lvl, err := OpenImage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lvl.Identifier != "sketch" {
+		t.Errorf("identifier is %q, want sketch", lvl.Identifier)
+	}
+	want := [][]Material{{Sand, Rain}, {Clay, Air}}
+	if !reflect.DeepEqual(lvl.Soil, want) {
+		t.Errorf("soil is %v, want %v", lvl.Soil, want)
+	}
+}
+
+func TestNearestMaterial(t *testing.T) {
+	for m := Air; m <= Rain; m++ {
+		if got := NearestMaterial(Palette[m]); got != m {
+			t.Errorf("the colour of %v maps to %v", m, got)
+		}
+	}
+	if got := NearestMaterial(Palette[Mud]); got == Mud {
+		t.Error("images should never map to mud")
+	}
+}
@@ -0,0 +1,228 @@
+package levels
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/solarlune/ldtkgo"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	soilLayer = "SoilType"
+	// Values per line LDtk uses when saving an IntGrid
+	ldtkCsvLine = 35
+)
+
+// LDtkSource reads the levels of an LDtk project. Soil comes from the
+// SoilType IntGrid layer, entities from every entity layer.
+type LDtkSource struct {
+	Path    string
+	mu      sync.Mutex
+	project *ldtkgo.Project
+}
+
+func (s *LDtkSource) Levels() ([]Level, error) {
+	project, err := ldtkgo.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.project = project
+	s.mu.Unlock()
+	return FromLDtk(project)
+}
+
+// Tileset resolves a tileset of the last loaded project, with its path
+// relative to the working directory.
+func (s *LDtkSource) Tileset(identifier string) (Tileset, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.project == nil {
+		return Tileset{}, false
+	}
+	ts := s.project.TilesetByIdentifier(identifier)
+	if ts == nil {
+		return Tileset{}, false
+	}
+	return Tileset{
+		Identifier: ts.Identifier,
+		Path:       filepath.Join(filepath.Dir(s.Path), ts.Path),
+		GridSize:   ts.GridSize,
+	}, true
+}
+
+func ldtkProperties(props []*ldtkgo.Property) map[string]any {
+	m := map[string]any{}
+	for _, p := range props {
+		m[p.Identifier] = p.Value
+	}
+	return m
+}
+
+// FromLDtk converts every level of a parsed project. Empty soil cells are
+// taken as air. Levels of linear worlds are laid side by side, in order.
+func FromLDtk(project *ldtkgo.Project) ([]Level, error) {
+	lvls := []Level{}
+	wx, wy := 0, 0
+	for _, l := range project.Levels {
+		layer := l.LayerByIdentifier(soilLayer)
+		if layer == nil {
+			return nil, fmt.Errorf("level %q has no %s layer", l.Identifier, soilLayer)
+		}
+		switch project.WorldLayout {
+		case ldtkgo.WorldLayoutHorizontal, ldtkgo.WorldLayoutVertical:
+		default:
+			wx, wy = l.WorldX/layer.GridSize, l.WorldY/layer.GridSize
+		}
+
+		soil := newSoil(layer.CellWidth, layer.CellHeight)
+		for _, i := range layer.IntGrid {
+			x, y := i.ID%layer.CellWidth, i.ID/layer.CellWidth
+			soil[x][y] = Material(i.Value)
+		}
+
+		entities := []Entity{}
+		tiles := []Tile{}
+		for _, layer := range l.Layers {
+			if layer.Tileset != nil {
+				for _, t := range layer.AllTiles() {
+					tiles = append(tiles, Tile{
+						Tileset: layer.Tileset.Identifier,
+						X:       t.Position[0] / layer.GridSize,
+						Y:       t.Position[1] / layer.GridSize,
+						ID:      t.ID,
+					})
+				}
+			}
+			if layer.Type != ldtkgo.LayerTypeEntity {
+				continue
+			}
+			for _, e := range layer.Entities {
+				entities = append(entities, Entity{
+					Identifier: e.Identifier,
+					X:          e.Position[0] / layer.GridSize,
+					Y:          e.Position[1] / layer.GridSize,
+					Width:      e.Width / layer.GridSize,
+					Height:     e.Height / layer.GridSize,
+					Properties: ldtkProperties(e.Properties),
+				})
+			}
+		}
+
+		lvls = append(lvls, Level{
+			Identifier: l.Identifier,
+			Soil:       soil,
+			Entities:   entities,
+			Tiles:      tiles,
+			Metadata:   ldtkProperties(l.Properties),
+			WorldX:     wx,
+			WorldY:     wy,
+		})
+		if project.WorldLayout == ldtkgo.WorldLayoutVertical {
+			wy += layer.CellHeight
+		} else {
+			wx += layer.CellWidth
+		}
+	}
+	return lvls, nil
+}
+
+// Export writes soil into the SoilType layer of a level and saves the
+// project to dst, which may be the source file itself. Only the IntGrid
+// array is rewritten, everything else is copied byte for byte.
+func (s *LDtkSource) Export(dst, identifier string, soil [][]Material) error {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return err
+	}
+	// Keep the mode of the file replaced, or of the project for a new one
+	info, err := os.Stat(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		info, err = os.Stat(s.Path)
+	}
+	if err != nil {
+		return err
+	}
+	data, err = ReplaceIntGrid(data, identifier, soil)
+	if err != nil {
+		return err
+	}
+
+	// Write to a sibling first, a watcher must never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// ReplaceIntGrid swaps the intGridCsv array of the SoilType layer of a
+// level in the raw project JSON, keeping LDtk's layout of the array.
+func ReplaceIntGrid(data []byte, identifier string, soil [][]Material) ([]byte, error) {
+	json := string(data)
+	path := ""
+	for i, l := range gjson.Get(json, "levels").Array() {
+		if l.Get("identifier").String() != identifier {
+			continue
+		}
+		for j, layer := range l.Get("layerInstances").Array() {
+			if layer.Get("__identifier").String() == soilLayer {
+				path = fmt.Sprintf("levels.%d.layerInstances.%d", i, j)
+				break
+			}
+		}
+	}
+	if path == "" {
+		return nil, fmt.Errorf("level %q has no %s layer", identifier, soilLayer)
+	}
+
+	layer := gjson.Get(json, path)
+	w, h := int(layer.Get("__cWid").Int()), int(layer.Get("__cHei").Int())
+	if len(soil) != w || len(soil[0]) != h {
+		return nil, fmt.Errorf("level %q is %dx%d, got a %dx%d grid", identifier, w, h, len(soil), len(soil[0]))
+	}
+	csv := gjson.Get(json, path+".intGridCsv")
+	if csv.Index == 0 {
+		return nil, fmt.Errorf("level %q: intGridCsv not found", identifier)
+	}
+
+	// Indent the values one level deeper than the line holding the key
+	lineStart := strings.LastIndexByte(json[:csv.Index], '\n') + 1
+	indent := json[lineStart:]
+	indent = indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+
+	buf := strings.Builder{}
+	buf.WriteString("[")
+	for i := 0; i < w*h; i++ {
+		if i%ldtkCsvLine == 0 {
+			buf.WriteString("\n" + indent + "\t")
+		}
+		buf.WriteString(strconv.Itoa(int(soil[i%w][i/w])))
+		if i < w*h-1 {
+			buf.WriteString(",")
+		}
+	}
+	buf.WriteString("\n" + indent + "]")
+
+	out := json[:csv.Index] + buf.String() + json[csv.Index+len(csv.Raw):]
+	return []byte(out), nil
+}
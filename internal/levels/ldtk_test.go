@@ -0,0 +1,101 @@
+package levels
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const demoProject = "../../soil-demo.ldtk"
+
+// copyProject copies the demo project to a temporary file with mode perm.
+func copyProject(t *testing.T, perm os.FileMode) (string, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(demoProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "project.ldtk")
+	if err := os.WriteFile(path, data, perm); err != nil {
+		t.Fatal(err)
+	}
+	// WriteFile is subject to the umask
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	path, orig := copyProject(t, 0o644)
+	src := &LDtkSource{Path: path}
+	lvls, err := src.Levels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lvl := lvls[0]
+
+	// Saving the level untouched leaves the file as it was
+	if err := src.Export(path, lvl.Identifier, lvl.Soil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, orig) {
+		t.Error("exporting an unchanged level changed the project")
+	}
+
+	soil := make([][]Material, len(lvl.Soil))
+	for x := range soil {
+		soil[x] = append([]Material{}, lvl.Soil[x]...)
+	}
+	soil[0][0], soil[len(soil)-1][len(soil[0])-1] = Rock, Clay
+	if err := src.Export(path, lvl.Identifier, soil); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("export changed the mode of the project to %v", info.Mode().Perm())
+	}
+
+	saved, err := src.Levels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(saved[0].Soil, soil) {
+		t.Error("the saved level doesn't hold the painted soil")
+	}
+	for i := 1; i < len(lvls); i++ {
+		if !reflect.DeepEqual(saved[i].Soil, lvls[i].Soil) {
+			t.Errorf("level %s changed", lvls[i].Identifier)
+		}
+	}
+
+	// A new file takes the mode of the project
+	dst := filepath.Join(filepath.Dir(path), "copy.ldtk")
+	if err := src.Export(dst, lvl.Identifier, soil); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dst); err != nil {
+		t.Error(err)
+	} else if info.Mode().Perm() != 0o644 {
+		t.Errorf("new file has mode %v, want the mode of the project", info.Mode().Perm())
+	}
+}
+
+func TestReplaceIntGridErrors(t *testing.T) {
+	_, orig := copyProject(t, 0o644)
+	lvls, err := (&LDtkSource{Path: demoProject}).Levels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplaceIntGrid(orig, "Nowhere", lvls[0].Soil); err == nil {
+		t.Error("an unknown level should be rejected")
+	}
+	if _, err := ReplaceIntGrid(orig, lvls[0].Identifier, lvls[0].Soil[1:]); err == nil {
+		t.Error("a grid of the wrong size should be rejected")
+	}
+}
@@ -0,0 +1,152 @@
+package levels
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+)
+
+// Material is the soil type of a cell. Values match the SoilType IntGrid
+// of the LDtk project.
+type Material int
+
+const (
+	Empty Material = iota
+	Air
+	LooseSoil
+	HardSoil
+	Sand
+	Clay
+	Rock
+	Rain
+	// Mud is left by erosion, soil images never map to it
+	Mud
+	// materialCount is the number of materials, new ones go before it
+	materialCount
+)
+
+var materialNames = map[string]Material{
+	"air":       Air,
+	"losesoil":  LooseSoil,
+	"loosesoil": LooseSoil,
+	"hardsoil":  HardSoil,
+	"sand":      Sand,
+	"clay":      Clay,
+	"rock":      Rock,
+	"rain":      Rain,
+	"mud":       Mud,
+}
+
+// Palette holds the preview colour of each material, the same as in the
+// LDtk project.
+var Palette = map[Material]color.RGBA{
+	Air:       {0xFF, 0xFF, 0xFF, 0xff},
+	LooseSoil: {0x27, 0x1F, 0x1E, 0xff},
+	HardSoil:  {0x55, 0x38, 0x29, 0xff},
+	Sand:      {0xEA, 0xD4, 0xAA, 0xff},
+	Clay:      {0xBE, 0x4A, 0x2F, 0xff},
+	Rock:      {0x5A, 0x69, 0x88, 0xff},
+	Rain:      {0x12, 0x4e, 0x89, 0xff},
+	Mud:       {0x7A, 0x5C, 0x3E, 0xff},
+}
+
+// Valid reports whether m is one of the materials above.
+func (m Material) Valid() bool {
+	return m >= Empty && m < materialCount
+}
+
+func (m Material) Color() color.RGBA {
+	if clr, ok := Palette[m]; ok {
+		return clr
+	}
+	return Palette[Air]
+}
+
+// MaterialByName resolves the identifiers used by the LDtk project, case
+// insensitive.
+func MaterialByName(name string) (Material, bool) {
+	m, ok := materialNames[strings.ToLower(name)]
+	return m, ok
+}
+
+// Entity is an object placed on a level, like a tree or a water source.
+// Position and size are in cells.
+type Entity struct {
+	Identifier    string
+	X, Y          int
+	Width, Height int
+	Properties    map[string]any
+}
+
+// Tile is a sprite placed on a level, from the tileset named Tileset.
+// Position is in cells.
+type Tile struct {
+	Tileset string
+	X, Y    int
+	ID      int
+}
+
+// Level is an editor independent soil layout. WorldX and WorldY place its
+// top left cell in the world, in cells; levels of one source never overlap.
+type Level struct {
+	Identifier     string
+	Soil           [][]Material // [x][y]
+	Entities       []Entity
+	Tiles          []Tile
+	Metadata       map[string]any
+	WorldX, WorldY int
+}
+
+func (l Level) Size() (int, int) {
+	return len(l.Soil), len(l.Soil[0])
+}
+
+func newSoil(w, h int) [][]Material {
+	soil := make([][]Material, w)
+	for x := range soil {
+		soil[x] = make([]Material, h)
+		for y := range soil[x] {
+			soil[x][y] = Air
+		}
+	}
+	return soil
+}
+
+// LevelSource yields levels from an editor file, an image or a generator.
+// Levels is called again on every reload, so file backed sources should
+// read their file each time.
+type LevelSource interface {
+	Levels() ([]Level, error)
+}
+
+// Tileset is an image split in square tiles, referenced by a level source.
+type Tileset struct {
+	Identifier string
+	Path       string
+	GridSize   int
+}
+
+// TilesetSource is implemented by level sources that also describe tilesets.
+type TilesetSource interface {
+	Tileset(identifier string) (Tileset, bool)
+}
+
+// Exporter is implemented by level sources that can save an edited soil
+// layout back to their format.
+type Exporter interface {
+	Export(dst, identifier string, soil [][]Material) error
+}
+
+// NewSource picks a level source for a file by its extension.
+func NewSource(path string) (LevelSource, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ldtk":
+		return &LDtkSource{Path: path}, nil
+	case ".tmx":
+		return &TiledSource{Paths: []string{path}}, nil
+	case ".png":
+		return &ImageSource{Paths: []string{path}}, nil
+	}
+	return nil, fmt.Errorf("%s: unknown level format", path)
+}
@@ -0,0 +1,41 @@
+package levels
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewSource(t *testing.T) {
+	for _, c := range []struct {
+		path string
+		want LevelSource
+	}{
+		{"world.ldtk", &LDtkSource{Path: "world.ldtk"}},
+		{"Map.TMX", &TiledSource{Paths: []string{"Map.TMX"}}},
+		{"sketch.png", &ImageSource{Paths: []string{"sketch.png"}}},
+	} {
+		src, err := NewSource(c.path)
+		if err != nil {
+			t.Errorf("%s: %s", c.path, err)
+		} else if !reflect.DeepEqual(src, c.want) {
+			t.Errorf("%s: got %#v, want %#v", c.path, src, c.want)
+		}
+	}
+	if _, err := NewSource("level.json"); err == nil {
+		t.Error("unknown formats should be rejected")
+	}
+}
+
+func TestMaterialByName(t *testing.T) {
+	for name, want := range map[string]Material{"loseSoil": LooseSoil, "LooseSoil": LooseSoil, "rock": Rock, "MUD": Mud} {
+		if m, ok := MaterialByName(name); !ok || m != want {
+			t.Errorf("%s is %v, want %v", name, m, want)
+		}
+	}
+	if _, ok := MaterialByName("lava"); ok {
+		t.Error("lava is not a material")
+	}
+	if !Empty.Valid() || !Rain.Valid() || Material(-1).Valid() || Material(60).Valid() {
+		t.Error("only the defined materials are valid")
+	}
+}
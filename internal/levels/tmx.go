@@ -0,0 +1,211 @@
+package levels
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lafriks/go-tiled"
+)
+
+// Reader for Tiled maps. Soil comes from tile layers and object groups named
+// SoilType, objects drawn over tiles. Tiles and objects carry their material
+// in a "material" custom property, either the LDtk value or its identifier.
+// Objects of any other group become entities.
+
+const materialProperty = "material"
+
+func tmxMaterial(props tiled.Properties) (Material, bool, error) {
+	for _, p := range props {
+		if p.Name != materialProperty {
+			continue
+		}
+		if v, err := strconv.Atoi(p.Value); err == nil && Material(v).Valid() {
+			return Material(v), true, nil
+		}
+		if m, ok := MaterialByName(p.Value); ok {
+			return m, true, nil
+		}
+		return Empty, false, fmt.Errorf("unknown material %q", p.Value)
+	}
+	return Empty, false, nil
+}
+
+// tileMaterial looks the material of a tile up in its tileset.
+func tileMaterial(tile *tiled.LayerTile) (Material, bool, error) {
+	for _, t := range tile.Tileset.Tiles {
+		if t.ID == tile.ID {
+			m, ok, err := tmxMaterial(t.Properties)
+			if err != nil {
+				return Empty, false, fmt.Errorf("tile %d: %w", t.ID, err)
+			}
+			return m, ok, nil
+		}
+	}
+	return Empty, false, nil
+}
+
+func tmxProperties(props tiled.Properties) map[string]any {
+	m := map[string]any{}
+	for _, p := range props {
+		m[p.Name] = p.Value
+	}
+	return m
+}
+
+// TiledSource reads one level from each Tiled map.
+type TiledSource struct {
+	Paths []string
+}
+
+func (s *TiledSource) Levels() ([]Level, error) {
+	lvls := []Level{}
+	wx := 0
+	for _, path := range s.Paths {
+		lvl, err := OpenTMX(path)
+		if err != nil {
+			return nil, err
+		}
+		// One file after the other, left to right
+		lvl.WorldX = wx
+		wx += len(lvl.Soil)
+		lvls = append(lvls, lvl)
+	}
+	return lvls, nil
+}
+
+func OpenTMX(path string) (Level, error) {
+	m, err := tiled.LoadFile(path)
+	if err != nil {
+		return Level{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Width <= 0 || m.Height <= 0 || m.TileWidth <= 0 || m.TileHeight <= 0 {
+		return Level{}, fmt.Errorf("%s: invalid map of %dx%d tiles of %dx%d pixels", path, m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+
+	soil := newSoil(m.Width, m.Height)
+	for _, l := range m.Layers {
+		if l.Name != soilLayer {
+			continue
+		}
+		if err := readTMXLayer(soil, m, l); err != nil {
+			return Level{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	entities := []Entity{}
+	for _, g := range m.ObjectGroups {
+		if g.Name != soilLayer {
+			entities = append(entities, tmxEntities(m, g)...)
+			continue
+		}
+		if err := readTMXObjects(soil, m, g); err != nil {
+			return Level{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	props := tiled.Properties{}
+	if m.Properties != nil {
+		props = *m.Properties
+	}
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if v := props.GetString("identifier"); v != "" {
+		id = v
+	}
+	return Level{
+		Identifier: id,
+		Soil:       soil,
+		Entities:   entities,
+		Metadata:   tmxProperties(props),
+	}, nil
+}
+
+// tmxRect converts an object to the cells whose center lies within it.
+func tmxRect(m *tiled.Map, o *tiled.Object) (x0, y0, x1, y1 int) {
+	top := o.Y
+	if o.GID != 0 {
+		// Tile objects are anchored on their bottom left corner
+		top -= o.Height
+	}
+	x0 = int(math.Round(o.X / float64(m.TileWidth)))
+	x1 = int(math.Round((o.X + o.Width) / float64(m.TileWidth)))
+	y0 = int(math.Round(top / float64(m.TileHeight)))
+	y1 = int(math.Round((top + o.Height) / float64(m.TileHeight)))
+	return x0, y0, x1, y1
+}
+
+func tmxEntities(m *tiled.Map, group *tiled.ObjectGroup) []Entity {
+	entities := []Entity{}
+	for _, o := range group.Objects {
+		id := o.Name
+		for _, alt := range []string{o.Class, o.Type} {
+			if id == "" {
+				id = alt
+			}
+		}
+		x0, y0, x1, y1 := tmxRect(m, o)
+		entities = append(entities, Entity{
+			Identifier: id,
+			X:          x0,
+			Y:          y0,
+			Width:      x1 - x0,
+			Height:     y1 - y0,
+			Properties: tmxProperties(o.Properties),
+		})
+	}
+	return entities
+}
+
+func readTMXLayer(soil [][]Material, m *tiled.Map, layer *tiled.Layer) error {
+	// Infinite maps keep their tiles in chunks, which are not read
+	if len(layer.Tiles) != m.Width*m.Height {
+		return fmt.Errorf("layer %s: expected %d tiles, got %d", layer.Name, m.Width*m.Height, len(layer.Tiles))
+	}
+	for i, tile := range layer.Tiles {
+		if tile.IsNil() {
+			continue
+		}
+		mat, ok, err := tileMaterial(tile)
+		if err != nil {
+			return fmt.Errorf("layer %s: %w", layer.Name, err)
+		}
+		if !ok {
+			return fmt.Errorf("layer %s: tile %d has no %s property", layer.Name, tile.ID, materialProperty)
+		}
+		soil[i%m.Width][i/m.Width] = mat
+	}
+	return nil
+}
+
+// readTMXObjects fills the cells covered by every object.
+func readTMXObjects(soil [][]Material, m *tiled.Map, group *tiled.ObjectGroup) error {
+	for i, o := range group.Objects {
+		mat, ok, err := tmxMaterial(o.Properties)
+		if err != nil {
+			return fmt.Errorf("object %d: %w", i, err)
+		}
+		if !ok && o.GID != 0 {
+			tile, err := m.TileGIDToTile(o.GID)
+			if err != nil {
+				return fmt.Errorf("object %d: %w", i, err)
+			}
+			if mat, ok, err = tileMaterial(tile); err != nil {
+				return fmt.Errorf("object %d: %w", i, err)
+			}
+		}
+		if !ok {
+			return fmt.Errorf("object %d has no %s property", i, materialProperty)
+		}
+
+		x0, y0, x1, y1 := tmxRect(m, o)
+		for x := x0; x < x1 && x < len(soil); x++ {
+			for y := y0; y < y1 && y < len(soil[x]); y++ {
+				if x >= 0 && y >= 0 {
+					soil[x][y] = mat
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package levels
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A 2x2 map: sand and hard soil on top, air and sand below, with a rock
+// object over the bottom right cell.
+var tmxGIDs = []uint32{1, 2, 0, 1}
+
+const tmxMapFormat = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="%d" height="2" tilewidth="%d" tileheight="8" infinite="0">
+ <properties>
+  <property name="identifier" value="Fixture"/>
+ </properties>
+ <tileset firstgid="1" name="soil" tilewidth="8" tileheight="8" tilecount="2" columns="2">
+  <tile id="0"><properties><property name="material" value="sand"/></properties></tile>
+  <tile id="1"><properties><property name="material" value="%s"/></properties></tile>
+ </tileset>
+ <layer id="1" name="SoilType" width="2" height="2">
+  %s
+ </layer>
+ <objectgroup id="2" name="SoilType">
+  <object id="1" x="8" y="8" width="8" height="8">
+   <properties><property name="material" value="rock"/></properties>
+  </object>
+ </objectgroup>
+ <objectgroup id="3" name="Things">
+  <object id="2" name="Nutrient" x="0" y="8" width="8" height="8">
+   <properties><property name="amount" value="5"/></properties>
+  </object>
+ </objectgroup>
+</map>`
+
+// tmxData encodes the fixture tiles as a layer data element.
+func tmxData(t *testing.T, encoding, compression string) string {
+	t.Helper()
+	switch encoding {
+	case "":
+		sb := strings.Builder{}
+		sb.WriteString("<data>")
+		for _, gid := range tmxGIDs {
+			fmt.Fprintf(&sb, `<tile gid="%d"/>`, gid)
+		}
+		sb.WriteString("</data>")
+		return sb.String()
+	case "csv":
+		fields := []string{}
+		for _, gid := range tmxGIDs {
+			fields = append(fields, fmt.Sprint(gid))
+		}
+		return `<data encoding="csv">` + strings.Join(fields, ",") + "</data>"
+	}
+
+	raw := bytes.Buffer{}
+	var w io.WriteCloser = nopCloser{&raw}
+	switch compression {
+	case "gzip":
+		w = gzip.NewWriter(&raw)
+	case "zlib":
+		w = zlib.NewWriter(&raw)
+	}
+	if err := binary.Write(w, binary.LittleEndian, tmxGIDs); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	attrs := `encoding="base64"`
+	if compression != "" {
+		attrs += fmt.Sprintf(` compression="%s"`, compression)
+	}
+	return fmt.Sprintf("<data %s>%s</data>", attrs, base64.StdEncoding.EncodeToString(raw.Bytes()))
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func writeTMX(t *testing.T, width, tileWidth int, material, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.tmx")
+	src := fmt.Sprintf(tmxMapFormat, width, tileWidth, material, data)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenTMX(t *testing.T) {
+	want := [][]Material{{Sand, Air}, {HardSoil, Rock}}
+	for _, c := range []struct{ encoding, compression string }{
+		{"", ""},
+		{"csv", ""},
+		{"base64", ""},
+		{"base64", "gzip"},
+		{"base64", "zlib"},
+	} {
+		name := strings.Trim(c.encoding+"/"+c.compression, "/")
+		if name == "" {
+			name = "xml"
+		}
+		t.Run(name, func(t *testing.T) {
+			lvl, err := OpenTMX(writeTMX(t, 2, 8, "3", tmxData(t, c.encoding, c.compression)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lvl.Identifier != "Fixture" {
+				t.Errorf("identifier is %q, want Fixture", lvl.Identifier)
+			}
+			if !reflect.DeepEqual(lvl.Soil, want) {
+				t.Errorf("soil is %v, want %v", lvl.Soil, want)
+			}
+			if len(lvl.Entities) != 1 {
+				t.Fatalf("got %d entities, want 1", len(lvl.Entities))
+			}
+			e := lvl.Entities[0]
+			if e.Identifier != "Nutrient" || e.X != 0 || e.Y != 1 || e.Width != 1 || e.Height != 1 || e.Properties["amount"] != "5" {
+				t.Errorf("unexpected entity %+v", e)
+			}
+		})
+	}
+}
+
+func TestOpenTMXErrors(t *testing.T) {
+	data := tmxData(t, "csv", "")
+	for _, c := range []struct {
+		name               string
+		width, tileWidth   int
+		material, contents string
+	}{
+		{"no width", 0, 8, "rock", data},
+		{"no tile width", 2, 0, "rock", data},
+		{"unknown material", 2, 8, "lava", data},
+		{"material out of range", 2, 8, "60", data},
+		{"negative material", 2, 8, "-1", data},
+		{"missing tiles", 2, 8, "rock", `<data encoding="csv">1,2,0</data>`},
+		{"unknown compression", 2, 8, "rock", `<data encoding="base64" compression="lz4">AAAA</data>`},
+	} {
+		if _, err := OpenTMX(writeTMX(t, c.width, c.tileWidth, c.material, c.contents)); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// A replay is a JSON lines file: a ReplayHeader, then one ReplayEvent per
+// user action in the order they happened, closed by an end event holding
+// the checksum of the final board. Project reloads and saves are not part
+// of it, replays expect the level file to be unchanged.
+
+const (
+	EventClick = "click"
+	EventPress = "press"
+	EventEnd   = "end"
+)
+
+// ReplayHeader holds what is needed to start the recorded run again.
+type ReplayHeader struct {
+	Map      string `json:"map"`
+	Generate bool   `json:"generate,omitempty"`
+	Seed     int64  `json:"seed"`
+	Level    uint   `json:"level"`
+	Speed    uint   `json:"speed"`
+	Paused   bool   `json:"paused,omitempty"`
+	Preview  bool   `json:"preview,omitempty"`
+	Edges    string `json:"edges,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	// Erosion holds the -erosion rules file, if one was given
+	Erosion json.RawMessage `json:"erosion,omitempty"`
+}
+
+// ReplayEvent is a user action stamped with the age of the scene. Clicks
+// are in board cells, presses name the toolbar widget by index.
+type ReplayEvent struct {
+	Age      uint   `json:"age"`
+	Kind     string `json:"kind"`
+	X        int    `json:"x,omitempty"`
+	Y        int    `json:"y,omitempty"`
+	Button   int    `json:"button,omitempty"`
+	Widget   int    `json:"widget,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Checksum uint64 `json:"checksum,omitempty"`
+}
+
+// Recorder writes a replay as the scene runs.
+type Recorder struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+	age uint
+	sum uint64
+	err error
+}
+
+func NewRecorder(path string, header ReplayHeader) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	r := &Recorder{f: f, w: w, enc: json.NewEncoder(w)}
+	if err := r.enc.Encode(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) Record(ev ReplayEvent) {
+	if r.err == nil {
+		r.err = r.enc.Encode(ev)
+	}
+}
+
+// Tick remembers the board at the start of a tick, the last one is written
+// by Close.
+func (r *Recorder) Tick(age uint, sum uint64) {
+	r.age, r.sum = age, sum
+}
+
+func (r *Recorder) Close() error {
+	r.Record(ReplayEvent{Age: r.age, Kind: EventEnd, Checksum: r.sum})
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// Replay feeds the events of a recording back to the scene.
+type Replay struct {
+	Header ReplayHeader
+	events []ReplayEvent
+	end    ReplayEvent
+	done   bool
+	err    error
+}
+
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	r := &Replay{}
+	if err := dec.Decode(&r.Header); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for dec.More() {
+		ev := ReplayEvent{}
+		if err := dec.Decode(&ev); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if ev.Kind == EventEnd {
+			r.end = ev
+			return r, nil
+		}
+		r.events = append(r.events, ev)
+	}
+	return nil, fmt.Errorf("%s: recording has no end, it was not closed properly", path)
+}
+
+// Next pops the next event due at age, if any.
+func (r *Replay) Next(age uint) (ReplayEvent, bool) {
+	if len(r.events) == 0 || r.events[0].Age > age {
+		return ReplayEvent{}, false
+	}
+	ev := r.events[0]
+	r.events = r.events[1:]
+	return ev, true
+}
+
+// Check compares the board with the recording once the end is reached.
+func (r *Replay) Check(age uint, sum uint64) {
+	if r.done || age < r.end.Age {
+		return
+	}
+	r.done = true
+	if sum != r.end.Checksum {
+		r.err = fmt.Errorf("replay diverged at age %d: board checksum %x, recorded %x", age, sum, r.end.Checksum)
+	}
+}
+
+func (r *Replay) Done() bool {
+	return r.done
+}
+
+// Err reports a mismatch with the recorded board, once Done.
+func (r *Replay) Err() error {
+	return r.err
+}
+
+// boardChecksum hashes the cells of a board. Painted materials change the
+// cells, so they are covered too.
+func boardChecksum(cells [][]mgl32.Vec2) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, row := range cells {
+		for _, c := range row {
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(c[0]))
+			binary.LittleEndian.PutUint32(buf[4:], math.Float32bits(c[1]))
+			h.Write(buf)
+		}
+	}
+	return h.Sum64()
+}
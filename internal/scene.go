@@ -1,176 +1,544 @@
 package internal
 
 import (
+	"fmt"
 	"image"
 	"image/color"
+	"log"
 	"math"
 
 	"github.com/go-gl/mathgl/mgl32"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/joelschutz/soil-demo/internal/boards"
-	"github.com/joelschutz/soil-demo/util"
+	"github.com/joelschutz/soil-demo/internal/levels"
+	"github.com/joelschutz/soil-demo/internal/widgets"
 	"github.com/joelschutz/stagehand"
-	"github.com/solarlune/ldtkgo"
 )
 
-type Board[V any] interface {
-	ebiten.Game
-	Size() (int, int)
-	Reset() error
-	Setup(m [][]V)
-	Click(btn ebiten.MouseButton)
-	Hover(x, y int)
-}
-
 type State struct {
 	age         uint
 	paused      bool
 	speed       uint
 	sceneNum    uint
-	hover       int
-	scaleFac    float64
-	isPreview   bool
+	camera      Camera
+	layers      Layers
 	menuScale   float64
-	Levels      []*ldtkgo.Level
+	Levels      []levels.Level
 	boardStates [][]mgl32.Vec2
 	Config      Config
+	watcher     *ProjectWatcher
+	keepHum     bool
+	exporter    levels.Exporter
+	exportPath  string
+	recorder    *Recorder
+	replay      *Replay
+	edges       [4]boards.Boundary
+	compare     []Comparison
+	showDiff    bool
+}
+
+// StartOptions holds the initial values of the user controllable state.
+type StartOptions struct {
+	Level  uint
+	Speed  uint
+	Paused bool
+	// Preview starts with the humidity layer hidden, showing the materials
+	Preview bool
+	// Watcher, if set, reloads the current level whenever the project changes
+	Watcher *ProjectWatcher
+	// KeepHumidity preserves the humidity of cells whose material did not
+	// change on reload
+	KeepHumidity bool
+	// Exporter, if set, saves the edited level to ExportPath on Ctrl+S
+	Exporter   levels.Exporter
+	ExportPath string
+	// Recorder, if set, saves every user action. Replay, if set, plays
+	// the actions of a recording instead of reading the input devices.
+	Recorder *Recorder
+	Replay   *Replay
+	// Edges are the boundary conditions of the humidity board
+	Edges [4]boards.Boundary
+	// Compare adds boards next to the main one, sharing its controls
+	Compare []Comparison
+}
+
+// Comparison is a board shown next to the main one, running a rule on its
+// own level and edges. NewBoard is the rule, the one of the scene if nil.
+type Comparison struct {
+	Level    uint
+	Edges    [4]boards.Boundary
+	NewBoard NewBoard
+}
+
+func NewState(lvls []levels.Level, conf Config, opts StartOptions) (State, error) {
+	if int(opts.Level) >= len(lvls) {
+		return State{}, fmt.Errorf("level %d out of range [0, %d)", opts.Level, len(lvls))
+	}
+	if opts.Speed >= Speeds {
+		return State{}, fmt.Errorf("speed %d out of range [0, %d)", opts.Speed, Speeds)
+	}
+	for _, c := range opts.Compare {
+		if int(c.Level) >= len(lvls) {
+			return State{}, fmt.Errorf("compared level %d out of range [0, %d)", c.Level, len(lvls))
+		}
+	}
+	st := State{
+		Levels:     lvls,
+		Config:     conf,
+		sceneNum:   opts.Level,
+		speed:      opts.Speed,
+		paused:     opts.Paused,
+		watcher:    opts.Watcher,
+		keepHum:    opts.KeepHumidity,
+		exporter:   opts.Exporter,
+		exportPath: opts.ExportPath,
+		recorder:   opts.Recorder,
+		replay:     opts.Replay,
+		edges:      opts.Edges,
+		compare:    opts.Compare,
+	}
+	st.layers = NewLayers()
+	st.layers.List[LayerHumidity].Hidden = opts.Preview
+	return st, nil
+}
+
+// Speeds is the number of speed steps, each running one more update per
+// tick. It doesn't depend on the theme, so replays keep their speed.
+const Speeds = 5
+
+// mouseButtons maps each boards.Button to its mouse button
+var mouseButtons = []ebiten.MouseButton{ebiten.MouseButtonLeft, ebiten.MouseButtonRight}
+
+// NewBoard creates the simulation of a level with the given edges.
+type NewBoard func(edges [4]boards.Boundary) boards.Simulation
+
+// HumidityRule is the default NewBoard of SimulationScene.
+func HumidityRule(edges [4]boards.Boundary) boards.Simulation {
+	return &boards.HumidityBoard{Edges: edges}
+}
+
+// ThermalRule adds the soil temperature to the humidity rule.
+func ThermalRule(edges [4]boards.Boundary) boards.Simulation {
+	return boards.NewThermalBoard(edges)
+}
+
+// NutrientRule lets the water of the humidity rule carry the nutrients of
+// the level.
+func NutrientRule(edges [4]boards.Boundary) boards.Simulation {
+	return boards.NewSoluteBoard(edges)
+}
+
+// ErosionRule lets the water reshape the terrain by the given rules.
+func ErosionRule(rules boards.ErosionRules) NewBoard {
+	return func(edges [4]boards.Boundary) boards.Simulation {
+		return boards.NewErosionBoard(edges, rules)
+	}
 }
 
 type SimulationScene struct {
-	Board   *boards.HumidityBoard
-	Preview Board[color.Color]
+	// NewBoard creates the main and compared boards, HumidityRule if nil
+	NewBoard NewBoard
+	// Board is the simulation of the current level, set on Load
+	Board   boards.Simulation
+	Preview boards.Board[color.Color]
 	sm      *stagehand.SceneManager[State]
 	state   State
+	menu    *widgets.Toolbar
+	// levels is the level button, whose count follows reloads
+	levels *widgets.Cycle
+	// switched is set once the scene hands over to the one of another level
+	switched bool
+	// panes hold every board on screen, the main one first and the compared
+	// ones right of it
+	panes []pane
+	paneW float64
+}
+
+type pane struct {
+	board   boards.Simulation
+	preview boards.Board[color.Color]
+	level   uint
+	// generation is the one of the board's materials shown by preview
+	generation uint
+	// Detail layers, built on first draw
+	grid, flux, trees *ebiten.Image
 }
 
 func (s *SimulationScene) Update() error {
-	if !s.state.paused {
-		for i := 0; i < int(s.state.speed+1); i++ {
-			s.Board.Update()
+	if s.state.watcher != nil {
+		lvls, err := s.state.watcher.Poll()
+		if err != nil {
+			log.Print(err)
+		}
+		if lvls != nil {
+			s.reload(lvls)
 		}
 	}
 
-	cx, cy := ebiten.CursorPosition()
-	if float64(cx) < float64(s.state.Config.btnSize)*s.state.menuScale {
-		s.state.hover = cy/int(float64(s.state.Config.btnSize)*s.state.menuScale) + 1
-		s.Board.Hover(-1, -1)
-	} else {
-		s.state.hover = 0
-		bx := int((float64(cx) - float64(s.state.Config.btnSize)*s.state.menuScale) / s.state.scaleFac)
-		by := int(float64(cy) / s.state.scaleFac)
-		s.Board.Hover(bx, by)
-		for btn := ebiten.MouseButtonLeft; btn < ebiten.MouseButtonMiddle; btn++ {
-			if ebiten.IsMouseButtonPressed(btn) {
-				s.Board.Click(btn)
+	replaying := s.state.replay != nil && !s.state.replay.Done()
+	if replaying || s.state.recorder != nil {
+		sum := boardChecksum(s.Board.GetState())
+		if replaying {
+			s.state.replay.Check(s.state.age, sum)
+			if err := s.state.replay.Err(); err != nil {
+				log.Print(err)
+			} else if s.state.replay.Done() {
+				log.Printf("Replay finished at age %d, the board matches the recording", s.state.age)
 			}
+		} else {
+			s.state.recorder.Tick(s.state.age, sum)
 		}
 	}
 
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
-		switch s.state.hover {
-		case 1:
-			s.state.paused = !s.state.paused
-		case 2:
-			s.Board.Reset()
-		case 3:
-			s.state.speed++
-			if s.state.speed > 4 {
-				s.state.speed = 0
-			}
-		case 4:
-			s.state.isPreview = !s.state.isPreview
-		case 5:
-			s.state.sceneNum++
-			if s.state.sceneNum > 3 {
-				s.state.sceneNum = 0
+	if !s.state.paused {
+		for i := 0; i < int(s.state.speed+1); i++ {
+			for _, p := range s.panes {
+				p.board.Update()
 			}
-			s.sm.SwitchTo(&SimulationScene{
-				Board:   &boards.HumidityBoard{},
-				Preview: &boards.EnumBoard{},
-			})
 		}
 	}
+
+	if replaying {
+		s.playEvents()
+	} else {
+		s.readInput()
+	}
+	s.refreshPreviews()
+
 	s.state.age++
 	return nil
 }
 
-func (s *SimulationScene) Draw(screen *ebiten.Image) {
-	img := ebiten.NewImage(s.Board.Size())
-	if !s.state.isPreview {
-		s.Board.Draw(img)
+func (s *SimulationScene) readInput() {
+	cx, cy := ebiten.CursorPosition()
+	overMenu := s.menu.Update(cx, cy)
+	s.state.camera.Update(cx, cy, overMenu)
+	// Every pane shares the camera, shifted by its position
+	px := float64(cx)
+	if s.paneW > 0 && px > s.menu.Width() {
+		px -= math.Floor((px-s.menu.Width())/s.paneW) * s.paneW
+	}
+	bx, by, ok := s.state.camera.ToCell(int(px), cy)
+	if w, h := s.Board.Size(); overMenu || !ok || bx < 0 || by < 0 || bx >= w || by >= h {
+		s.hover(-1, -1)
 	} else {
-		s.Preview.Draw(img)
+		s.hover(bx, by)
+		for i, mb := range mouseButtons {
+			if btn := boards.Button(i); ebiten.IsMouseButtonPressed(mb) {
+				s.click(btn)
+				if s.state.recorder != nil {
+					s.state.recorder.Record(ReplayEvent{Age: s.state.age, Kind: EventClick, X: bx, Y: by, Button: int(btn)})
+				}
+			}
+		}
 	}
 
-	op := &ebiten.DrawImageOptions{}
-	s.state.scaleFac = float64(screen.Bounds().Dy()) / float64(img.Bounds().Dy())
-	op.GeoM.Scale(s.state.scaleFac, s.state.scaleFac)
-	op.GeoM.Translate(float64(s.state.Config.btnSize)*s.state.menuScale, 0)
-	screen.DrawImage(img, op)
-
-	invertedClr := ebiten.ColorM{}
-	invertedClr.Scale(-1, -1, -1, 1)
-	invertedClr.Translate(1, 1, 1, 0)
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		s.export()
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyD) && len(s.panes) > 1 {
+		s.state.showDiff = !s.state.showDiff
+	}
+	s.state.layers.Update()
+}
 
-	opPlay := &ebiten.DrawImageOptions{}
-	opPlay.GeoM.Scale(s.state.menuScale, s.state.menuScale)
+// hover points every board at the same cell.
+func (s *SimulationScene) hover(x, y int) {
+	for _, p := range s.panes {
+		p.board.Hover(x, y)
+		p.preview.Hover(x, y)
+	}
+}
 
-	opReset := &ebiten.DrawImageOptions{}
-	opReset.GeoM.Scale(s.state.menuScale, s.state.menuScale)
-	opReset.GeoM.Translate(0, float64(s.state.Config.btnSize)*s.state.menuScale)
+// click paints on every board, so compared boards stay comparable.
+func (s *SimulationScene) click(btn boards.Button) {
+	for _, p := range s.panes {
+		p.board.Click(btn)
+	}
+}
 
-	opSpeed := &ebiten.DrawImageOptions{}
-	opSpeed.GeoM.Scale(s.state.menuScale, s.state.menuScale)
-	opSpeed.GeoM.Translate(0, 2*float64(s.state.Config.btnSize)*s.state.menuScale)
+// refreshPreviews rebuilds the previews whose board changed its materials,
+// by painting, resetting or the rule itself.
+func (s *SimulationScene) refreshPreviews() {
+	for i := range s.panes {
+		p := &s.panes[i]
+		if g := p.board.Generation(); g != p.generation {
+			p.preview.Setup(boards.MakeColorGrid(p.board.Materials()))
+			p.generation = g
+		}
+	}
+}
 
-	opTree := &ebiten.DrawImageOptions{}
-	opTree.GeoM.Scale(s.state.menuScale, s.state.menuScale)
-	opTree.GeoM.Translate(0, 3*float64(s.state.Config.btnSize)*s.state.menuScale)
-	if s.state.isPreview {
-		opTree.ColorM = invertedClr
+// playEvents applies the recorded actions due this tick.
+func (s *SimulationScene) playEvents() {
+	for {
+		ev, ok := s.state.replay.Next(s.state.age)
+		if !ok {
+			return
+		}
+		switch ev.Kind {
+		case EventClick:
+			s.hover(ev.X, ev.Y)
+			s.click(boards.Button(ev.Button))
+		case EventPress:
+			s.menu.Press(ev.Widget)
+			if s.switched {
+				// The rest of the events belong to the new scene
+				return
+			}
+		}
 	}
+}
 
-	opScene := &ebiten.DrawImageOptions{}
-	opScene.GeoM.Scale(s.state.menuScale, s.state.menuScale)
-	opScene.GeoM.Translate(0, 4*float64(s.state.Config.btnSize)*s.state.menuScale)
+func (s *SimulationScene) Draw(screen *ebiten.Image) {
+	// Without zoom the boards fill their pane, split evenly right of the menu
+	bounds := screen.Bounds()
+	s.paneW = (float64(bounds.Dx()) - s.menu.Width()) / float64(len(s.panes))
+	fit := math.Inf(1)
+	for _, p := range s.panes {
+		w, h := p.board.Size()
+		fit = math.Min(fit, s.paneW/float64(w))
+		fit = math.Min(fit, float64(bounds.Dy())/float64(h))
+	}
+	s.state.camera.SetView(s.menu.Width(), 0, fit)
 
-	switch s.state.hover {
-	case 1:
-		opPlay.ColorM = invertedClr
-	case 2:
-		opReset.ColorM = invertedClr
-	case 3:
-		opSpeed.ColorM = invertedClr
-	case 5:
-		opScene.ColorM = invertedClr
+	size := s.detailSize()
+	for i := range s.panes {
+		x0 := s.menu.Width() + float64(i)*s.paneW
+		view := screen.SubImage(image.Rect(int(x0), 0, int(x0+s.paneW), bounds.Dy())).(*ebiten.Image)
+		geo := s.state.camera.GeoM()
+		geo.Translate(float64(i)*s.paneW, 0)
+		s.state.layers.Draw(view, geo, s.layerImages(&s.panes[i], i, size), size)
 	}
 
 	// Draw MENU
-	if s.state.paused {
-		screen.DrawImage(s.state.Config.pauseBtn, opPlay)
+	s.menu.Draw(screen)
+	s.state.layers.DrawHUD(screen, int(s.menu.Width()))
+}
+
+// layerImages renders the layers of the i-th pane. The humidity layer shows
+// the difference to the main board instead when enabled.
+func (s *SimulationScene) layerImages(p *pane, i, size int) [layerCount]*ebiten.Image {
+	w, h := p.board.Size()
+	imgs := [layerCount]*ebiten.Image{}
+	imgs[LayerMaterials] = ebiten.NewImage(w, h)
+	p.preview.Draw(imgs[LayerMaterials])
+	imgs[LayerHumidity] = ebiten.NewImage(w, h)
+	if s.state.showDiff && i > 0 {
+		boards.DrawDifference(imgs[LayerHumidity], p.board.GetState(), s.Board.GetState())
 	} else {
-		screen.DrawImage(s.state.Config.playBtn, opPlay)
+		p.board.Draw(imgs[LayerHumidity])
+	}
+	if thermal, ok := p.board.(boards.Thermal); ok {
+		imgs[LayerTemperature] = ebiten.NewImage(w, h)
+		thermal.DrawTemperature(imgs[LayerTemperature])
+	}
+	if carrier, ok := p.board.(boards.SoluteCarrier); ok {
+		imgs[LayerSolute] = ebiten.NewImage(w, h)
+		carrier.DrawSolute(imgs[LayerSolute])
+	}
+
+	if p.grid == nil {
+		p.grid = ebiten.NewImageFromImage(GridImage(w, h, size))
+	}
+	imgs[LayerGrid] = p.grid
+	if !s.state.layers.List[LayerFlux].Hidden {
+		if p.flux == nil {
+			p.flux = ebiten.NewImage(w*size, h*size)
+		}
+		p.flux.Clear()
+		if flow, ok := p.board.(boards.Flow); ok {
+			DrawFlow(p.flux, flow, w, h, size)
+		}
+		imgs[LayerFlux] = p.flux
+	}
+	if trees := s.state.Config.Trees(); p.trees == nil && trees != nil {
+		p.trees = ebiten.NewImageFromImage(TreeImage(s.state.Levels[p.level], trees, size))
+	}
+	imgs[LayerTrees] = p.trees
+	return imgs
+}
+
+// detailSize is the resolution of the detail layers, in pixels per cell.
+// It matches the tree tiles so they are drawn unscaled.
+func (s *SimulationScene) detailSize() int {
+	if trees := s.state.Config.Trees(); trees != nil {
+		return int(trees.TXSize())
 	}
-	screen.DrawImage(s.state.Config.resetBtn, opReset)
-	screen.DrawImage(s.state.Config.speedBtn.SubImage(image.Rect(int(s.state.speed)*s.state.Config.btnSize, 0, (int(s.state.speed)+1)*s.state.Config.btnSize, s.state.Config.btnSize)).(*ebiten.Image), opSpeed)
-	screen.DrawImage(s.state.Config.treeBtn, opTree)
-	screen.DrawImage(s.state.Config.sceneBtn.SubImage(image.Rect(int(s.state.sceneNum)*s.state.Config.btnSize, 0, (int(s.state.sceneNum)+1)*s.state.Config.btnSize, s.state.Config.btnSize)).(*ebiten.Image), opScene)
+	return detailSize
+}
 
+func (s *SimulationScene) newMenu() *widgets.Toolbar {
+	conf := s.state.Config
+	s.levels = &widgets.Cycle{
+		Strip:  conf.Sprite("scene").Image,
+		Frames: uint(conf.Sprite("scene").Frames),
+		Count:  uint(len(s.state.Levels)),
+		Text:   "Next Level",
+		Value:  &s.state.sceneNum,
+		OnChange: func(uint) {
+			// The new scene starts on the next tick, so ages never
+			// repeat in a recording
+			s.state.age++
+			s.switched = true
+			s.sm.SwitchTo(&SimulationScene{
+				NewBoard: s.NewBoard,
+				Preview:  &boards.EnumBoard{},
+			})
+		},
+	}
+	return &widgets.Toolbar{
+		BtnSize: conf.btnSize,
+		Scale:   s.state.menuScale,
+		Widgets: []widgets.Widget{
+			&widgets.Toggle{
+				On:    conf.Sprite("play").Image,
+				Off:   conf.Sprite("pause").Image,
+				Text:  "Play/Pause",
+				Value: &s.state.paused,
+			},
+			&widgets.Button{
+				Sprite:  conf.Sprite("reset").Image,
+				Text:    "Reset",
+				OnClick: func() { s.reset() },
+			},
+			&widgets.Cycle{
+				Strip:  conf.Sprite("speed").Image,
+				Frames: uint(conf.Sprite("speed").Frames),
+				Count:  Speeds,
+				Text:   "Speed",
+				Value:  &s.state.speed,
+			},
+			&widgets.Toggle{
+				On:    conf.Sprite("tree").Image,
+				Text:  "Soil Types",
+				Value: &s.state.layers.List[LayerHumidity].Hidden,
+			},
+			s.levels,
+		},
+	}
 }
 
 func (s *SimulationScene) Load(state State, manager *stagehand.SceneManager[State]) {
 	s.state = state
 	s.sm = manager
+	s.menu = s.newMenu()
+	if s.state.recorder != nil {
+		s.menu.OnPress = func(i int) {
+			s.state.recorder.Record(ReplayEvent{
+				Age:    s.state.age,
+				Kind:   EventPress,
+				Widget: i,
+				Name:   s.menu.Widgets[i].Tooltip(),
+			})
+		}
+	}
+	s.setupLevel()
+}
+
+func (s *SimulationScene) reset() {
+	for _, p := range s.panes {
+		p.board.Reset()
+	}
+}
+
+func (s *SimulationScene) setupLevel() {
+	lvl := s.state.Levels[s.state.sceneNum]
+
+	if s.NewBoard == nil {
+		s.NewBoard = HumidityRule
+	}
+	s.Board = s.NewBoard(s.state.edges)
+	loadBoard(s.Board, lvl)
+	s.Preview.Setup(boards.MakeColorGrid(lvl.Soil))
+
+	s.panes = []pane{{board: s.Board, preview: s.Preview, level: s.state.sceneNum, generation: s.Board.Generation()}}
+	for _, c := range s.state.compare {
+		if int(c.Level) >= len(s.state.Levels) {
+			// Gone after a reload
+			continue
+		}
+		lvl := s.state.Levels[c.Level]
+		newBoard := c.NewBoard
+		if newBoard == nil {
+			newBoard = s.NewBoard
+		}
+		p := pane{
+			board:   newBoard(c.Edges),
+			preview: &boards.EnumBoard{},
+			level:   c.Level,
+		}
+		loadBoard(p.board, lvl)
+		p.preview.Setup(boards.MakeColorGrid(lvl.Soil))
+		p.generation = p.board.Generation()
+		s.panes = append(s.panes, p)
+	}
+	s.hover(-1, -1)
+}
 
-	soilGrid := s.state.Levels[s.state.sceneNum].LayerByIdentifier("SoilType").IntGrid
+// loadBoard sets a board up from a level, with its solute sources if the
+// board carries any.
+func loadBoard(board boards.Simulation, lvl levels.Level) {
+	board.Load(lvl.Soil)
+	if carrier, ok := board.(boards.SoluteCarrier); ok {
+		if err := carrier.LoadSources(lvl.Entities); err != nil {
+			log.Printf("Solute Sources Fail: %s: %s", lvl.Identifier, err)
+		}
+	}
+}
+
+// reload swaps the levels for the ones of a freshly parsed project and
+// rebuilds the current one in place.
+func (s *SimulationScene) reload(lvls []levels.Level) {
+	if len(lvls) == 0 {
+		log.Print("reloaded project has no levels, ignoring")
+		return
+	}
+	s.state.Levels = lvls
+	s.levels.Count = uint(len(lvls))
+	if int(s.state.sceneNum) >= len(lvls) {
+		s.state.sceneNum = 0
+	}
+
+	oldSoil, oldHum := s.Board.Materials(), s.Board.GetState()
+	s.setupLevel()
+	soil := s.Board.Materials()
+	if !s.state.keepHum || len(oldSoil) != len(soil) || len(oldSoil[0]) != len(soil[0]) {
+		return
+	}
 
-	hum, rocks, rain := MakeSoilGrid(16, soilGrid)
-	s.Board.Rain = rain
-	s.Board.Rocks = rocks
-	s.Board.Setup(hum)
+	// Setup shares its slice with the reset state, so work on a copy
+	hum := [][]mgl32.Vec2{}
+	for x, row := range s.Board.GetState() {
+		hum = append(hum, append([]mgl32.Vec2{}, row...))
+		for y := range row {
+			if oldSoil[x][y] == soil[x][y] && soil[x][y] != levels.Rain {
+				hum[x][y][0] = oldHum[x][y][0]
+			}
+		}
+	}
+	s.Board.SetState(hum)
+}
 
-	s.Preview.Setup(MakeColorGrid(16, soilGrid))
+func (s *SimulationScene) export() {
+	if s.state.exporter == nil {
+		log.Print("Export Fail: the level source can't be saved")
+		return
+	}
+	lvl := s.state.Levels[s.state.sceneNum]
+	// The board keeps painting on its own materials
+	lvl.Soil = nil
+	for _, row := range s.Board.Materials() {
+		lvl.Soil = append(lvl.Soil, append([]levels.Material{}, row...))
+	}
+	if err := s.state.exporter.Export(s.state.exportPath, lvl.Identifier, lvl.Soil); err != nil {
+		log.Printf("Export Fail: %s", err)
+		return
+	}
+	// Keep the edits when coming back to this level
+	s.state.Levels[s.state.sceneNum] = lvl
+	log.Printf("Saved %s to %s", lvl.Identifier, s.state.exportPath)
 }
 
 func (s *SimulationScene) Unload() State {
@@ -182,57 +550,6 @@ func (s *SimulationScene) Layout(outsideWidth, outsideHeight int) (int, int) {
 	if s.state.menuScale > 4 {
 		s.state.menuScale = 4
 	}
+	s.menu.Scale = s.state.menuScale
 	return outsideWidth, outsideHeight
 }
-
-func MakeSoilGrid(size int, ldtkMap []*ldtkgo.Integer) (hum [][]mgl32.Vec2, rocks, rain [][]bool) {
-	// Create Air Grid
-	hum = util.MakeMatrix(size, mgl32.Vec2{0, 1})
-	rocks = util.MakeMatrixBool(size)
-	rain = util.MakeMatrixBool(size)
-	for i, row := range hum {
-		for j := range row {
-			cell := ldtkMap[(j*16)+i].Value
-			switch cell {
-			case 1: // Air tile
-				continue
-			case 7: // Cloud Tile
-				hum[i][j][0] = 1023
-				rain[i][j] = true
-			case 6: // Rock Tile
-				hum[i][j][1] = math.MaxFloat32
-				rocks[i][j] = true
-			default:
-				hum[i][j][1] = float32(math.Pow(5, float64(cell)))
-			}
-		}
-	}
-	return hum, rocks, rain
-}
-
-func MakeColorGrid(size int, ldtkMap []*ldtkgo.Integer) [][]color.Color {
-	clrs := make([][]color.Color, size)
-	for i := range clrs {
-		clrs[i] = make([]color.Color, size)
-		for j := range clrs[i] {
-			clr := color.RGBA{0xff, 0xff, 0xff, 0xff}
-			cell := ldtkMap[(j*16)+i].Value
-			switch cell {
-			case 7: // Cloud Tile
-				clr = color.RGBA{0x12, 0x4e, 0x89, 0xff}
-			case 6: // Rock Tile
-				clr = color.RGBA{0x5A, 0x69, 0x88, 0xff}
-			case 5: // Clay Tile
-				clr = color.RGBA{0xBE, 0x4A, 0x2F, 0xff}
-			case 4: // Sand Tile
-				clr = color.RGBA{0xEA, 0xD4, 0xAA, 0xff}
-			case 3: // hardSoil Tile
-				clr = color.RGBA{0x55, 0x38, 0x29, 0xff}
-			case 2: // looseSoil Tile
-				clr = color.RGBA{0x27, 0x1F, 0x1E, 0xff}
-			}
-			clrs[i][j] = clr
-		}
-	}
-	return clrs
-}
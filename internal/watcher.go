@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joelschutz/soil-demo/internal/levels"
+)
+
+// ProjectWatcher polls a level file on disk and reloads its source every
+// time its modification time changes.
+type ProjectWatcher struct {
+	src     levels.LevelSource
+	path    string
+	modTime time.Time
+	failed  time.Time
+	updates chan []levels.Level
+	errs    chan error
+	done    chan struct{}
+}
+
+func WatchProject(src levels.LevelSource, path string, interval time.Duration) (*ProjectWatcher, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &ProjectWatcher{
+		src:     src,
+		path:    path,
+		modTime: info.ModTime(),
+		updates: make(chan []levels.Level, 1),
+		errs:    make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+	go w.run(interval)
+	return w, nil
+}
+
+func (w *ProjectWatcher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(w.path)
+		if err != nil || info.ModTime().Equal(w.modTime) || info.ModTime().Equal(w.failed) {
+			continue
+		}
+		lvls, err := w.src.Levels()
+		if err != nil {
+			// Reported once per write, a later save will be picked up again
+			w.failed = info.ModTime()
+			send(w.errs, fmt.Errorf("reloading %s: %w", w.path, err))
+			continue
+		}
+		w.modTime = info.ModTime()
+		send(w.updates, lvls)
+	}
+}
+
+// send replaces any value still waiting in the channel, so the reader
+// always gets the latest one.
+func send[V any](ch chan V, v V) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- v
+}
+
+// Poll returns the latest parsed levels, if the file changed since the
+// last call, and the latest parsing error, if any. It never blocks.
+func (w *ProjectWatcher) Poll() (lvls []levels.Level, err error) {
+	select {
+	case lvls = <-w.updates:
+	default:
+	}
+	select {
+	case err = <-w.errs:
+	default:
+	}
+	return lvls, err
+}
+
+func (w *ProjectWatcher) Close() {
+	close(w.done)
+}
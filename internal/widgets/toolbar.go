@@ -0,0 +1,76 @@
+package widgets
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Toolbar stacks widgets vertically on the left edge of the screen. Every
+// widget occupies a BtnSize square scaled by Scale.
+type Toolbar struct {
+	Widgets []Widget
+	BtnSize int
+	Scale   float64
+	// OnPress, if set, is told the index of every widget pressed
+	OnPress func(i int)
+	hover   int
+}
+
+func (tb *Toolbar) cellSize() float64 {
+	return float64(tb.BtnSize) * tb.Scale
+}
+
+// Width returns the horizontal space taken by the toolbar on screen.
+func (tb *Toolbar) Width() float64 {
+	return tb.cellSize()
+}
+
+// Update tracks the hovered widget and fires its Click on a left press.
+// It reports whether the cursor is over the toolbar column.
+func (tb *Toolbar) Update(cx, cy int) bool {
+	tb.hover = -1
+	if float64(cx) >= tb.cellSize() {
+		return false
+	}
+	if i := int(float64(cy) / tb.cellSize()); i < len(tb.Widgets) {
+		tb.hover = i
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			tb.Press(i)
+		}
+	}
+	return true
+}
+
+// Press clicks the i-th widget as if it was pressed with the mouse.
+func (tb *Toolbar) Press(i int) {
+	if i < 0 || i >= len(tb.Widgets) {
+		return
+	}
+	if tb.OnPress != nil {
+		tb.OnPress(i)
+	}
+	tb.Widgets[i].Click()
+}
+
+func (tb *Toolbar) Draw(screen *ebiten.Image) {
+	invertedClr := ebiten.ColorM{}
+	invertedClr.Scale(-1, -1, -1, 1)
+	invertedClr.Translate(1, 1, 1, 0)
+
+	for i, w := range tb.Widgets {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(tb.Scale, tb.Scale)
+		op.GeoM.Translate(0, float64(i)*tb.cellSize())
+		if i == tb.hover || w.Active() {
+			op.ColorM = invertedClr
+		}
+		screen.DrawImage(w.Image(), op)
+	}
+
+	if tb.hover >= 0 {
+		if text := tb.Widgets[tb.hover].Tooltip(); text != "" {
+			ebitenutil.DebugPrintAt(screen, text, int(tb.cellSize())+4, int(float64(tb.hover)*tb.cellSize()))
+		}
+	}
+}
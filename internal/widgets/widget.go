@@ -0,0 +1,114 @@
+package widgets
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Widget is a single square element of a Toolbar.
+type Widget interface {
+	Image() *ebiten.Image
+	Active() bool
+	Tooltip() string
+	Click()
+}
+
+// Button calls OnClick every time it is pressed.
+type Button struct {
+	Sprite  *ebiten.Image
+	Text    string
+	OnClick func()
+}
+
+func (b *Button) Image() *ebiten.Image {
+	return b.Sprite
+}
+
+func (b *Button) Active() bool {
+	return false
+}
+
+func (b *Button) Tooltip() string {
+	return b.Text
+}
+
+func (b *Button) Click() {
+	if b.OnClick != nil {
+		b.OnClick()
+	}
+}
+
+// Toggle flips Value on every press. When Off is nil the On sprite is
+// drawn inverted while the toggle is active.
+type Toggle struct {
+	On, Off  *ebiten.Image
+	Text     string
+	Value    *bool
+	OnChange func(bool)
+}
+
+func (t *Toggle) Image() *ebiten.Image {
+	if *t.Value && t.Off != nil {
+		return t.Off
+	}
+	return t.On
+}
+
+func (t *Toggle) Active() bool {
+	return *t.Value && t.Off == nil
+}
+
+func (t *Toggle) Tooltip() string {
+	return t.Text
+}
+
+func (t *Toggle) Click() {
+	*t.Value = !*t.Value
+	if t.OnChange != nil {
+		t.OnChange(*t.Value)
+	}
+}
+
+// Cycle steps Value through the frames of a horizontal sprite strip,
+// wrapping back to the first one after the last. With Count set it wraps
+// after Count values instead, showing the last frame for the ones past it.
+type Cycle struct {
+	Strip    *ebiten.Image
+	Frames   uint
+	Count    uint
+	Text     string
+	Value    *uint
+	OnChange func(uint)
+}
+
+func (c *Cycle) Image() *ebiten.Image {
+	w := c.Strip.Bounds().Dx() / int(c.Frames)
+	i := int(*c.Value)
+	if i >= int(c.Frames) {
+		i = int(c.Frames) - 1
+	}
+	return c.Strip.SubImage(image.Rect(i*w, 0, (i+1)*w, c.Strip.Bounds().Dy())).(*ebiten.Image)
+}
+
+func (c *Cycle) Active() bool {
+	return false
+}
+
+func (c *Cycle) Tooltip() string {
+	return c.Text
+}
+
+func (c *Cycle) Click() {
+	count := c.Count
+	if count == 0 {
+		count = c.Frames
+	}
+	*c.Value++
+	if *c.Value >= count {
+		*c.Value = 0
+	}
+	if c.OnChange != nil {
+		c.OnChange(*c.Value)
+	}
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/joelschutz/soil-demo/internal/boards"
+	"github.com/joelschutz/soil-demo/internal/widgets"
+	"github.com/joelschutz/stagehand"
+)
+
+// WorldScene simulates all the levels at once as the chunks of a
+// boards.World. The scene cycle moves the focus from chunk to chunk.
+type WorldScene struct {
+	World *boards.World
+	state State
+	menu  *widgets.Toolbar
+}
+
+func (s *WorldScene) Update() error {
+	if !s.state.paused {
+		for i := 0; i < int(s.state.speed+1); i++ {
+			s.World.Update()
+		}
+	}
+
+	cx, cy := ebiten.CursorPosition()
+	overMenu := s.menu.Update(cx, cy)
+	s.state.camera.Update(cx, cy, overMenu)
+	if bx, by, ok := s.state.camera.ToCell(cx, cy); overMenu || !ok {
+		s.World.Hover(-1, -1)
+	} else {
+		// The world ignores cells outside its chunks
+		s.World.Hover(bx, by)
+		for i, mb := range mouseButtons {
+			if ebiten.IsMouseButtonPressed(mb) {
+				s.World.Click(boards.Button(i))
+			}
+		}
+	}
+
+	s.state.age++
+	return nil
+}
+
+func (s *WorldScene) Draw(screen *ebiten.Image) {
+	img := ebiten.NewImage(s.World.Size())
+	s.World.Draw(img)
+
+	// Fit the whole world right of the menu
+	w, h := screen.Bounds().Dx()-int(s.menu.Width()), screen.Bounds().Dy()
+	fit := math.Min(float64(w)/float64(img.Bounds().Dx()), float64(h)/float64(img.Bounds().Dy()))
+	s.state.camera.SetView(s.menu.Width(), 0, fit)
+	op := &ebiten.DrawImageOptions{GeoM: s.state.camera.GeoM()}
+	screen.DrawImage(img, op)
+
+	s.menu.Draw(screen)
+}
+
+func (s *WorldScene) newMenu() *widgets.Toolbar {
+	conf := s.state.Config
+	return &widgets.Toolbar{
+		BtnSize: conf.btnSize,
+		Scale:   s.state.menuScale,
+		Widgets: []widgets.Widget{
+			&widgets.Toggle{
+				On:    conf.Sprite("play").Image,
+				Off:   conf.Sprite("pause").Image,
+				Text:  "Play/Pause",
+				Value: &s.state.paused,
+			},
+			&widgets.Button{
+				Sprite:  conf.Sprite("reset").Image,
+				Text:    "Reset",
+				OnClick: func() { s.World.Reset() },
+			},
+			&widgets.Cycle{
+				Strip:  conf.Sprite("speed").Image,
+				Frames: uint(conf.Sprite("speed").Frames),
+				Text:   "Speed",
+				Value:  &s.state.speed,
+			},
+			&widgets.Cycle{
+				Strip:  conf.Sprite("scene").Image,
+				Frames: uint(conf.Sprite("scene").Frames),
+				Count:  uint(len(s.World.Chunks)),
+				Text:   "Next Chunk",
+				Value:  &s.state.sceneNum,
+				OnChange: func(i uint) {
+					s.World.SetFocus(int(i))
+				},
+			},
+		},
+	}
+}
+
+func (s *WorldScene) Load(state State, manager *stagehand.SceneManager[State]) {
+	s.state = state
+	s.menu = s.newMenu()
+	s.World.SetFocus(int(s.state.sceneNum) % len(s.World.Chunks))
+}
+
+func (s *WorldScene) Unload() State {
+	return s.state
+}
+
+func (s *WorldScene) Layout(outsideWidth, outsideHeight int) (int, int) {
+	s.state.menuScale = math.Min(float64(outsideHeight)/160, 4)
+	s.menu.Scale = s.state.menuScale
+	return outsideWidth, outsideHeight
+}
@@ -1,47 +1,385 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/joelschutz/soil-demo/internal"
 	"github.com/joelschutz/soil-demo/internal/boards"
+	"github.com/joelschutz/soil-demo/internal/levels"
 	"github.com/joelschutz/stagehand"
-	"github.com/solarlune/ldtkgo"
 )
 
-const (
-	screenWidth  = 640
-	screenHeight = 480
-)
+// errUsage marks errors already reported by the flag package.
+var errUsage = errors.New("invalid usage")
 
-var (
-	ldtkProject *ldtkgo.Project
-)
+type options struct {
+	mapPath    string
+	level      string
+	width      int
+	height     int
+	fullscreen bool
+	speed      uint
+	paused     bool
+	board      string
+	themeDir   string
+	watch      bool
+	keepHum    bool
+	saveTo     string
+	generate   bool
+	seed       int64
+	record     string
+	replay     string
+	headless   bool
+	edges      string
+	world      bool
+	compare    string
+	cmpEdges   string
+	rule       string
+	erosion    string
+}
 
-func main() {
-	ebiten.SetWindowSize(screenWidth, screenHeight)
-	ebiten.SetWindowTitle("Soil Demo")
-	ebiten.SetWindowResizable(true)
+// rules are the simulations selectable with -rule.
+var rules = map[string]internal.NewBoard{
+	"humidity":  internal.HumidityRule,
+	"thermal":   internal.ThermalRule,
+	"nutrients": internal.NutrientRule,
+	"erosion":   internal.ErosionRule(boards.DefaultErosionRules()),
+}
+
+func parseFlags(args []string) (options, error) {
+	opts := options{}
+	fset := flag.NewFlagSet("soil-demo", flag.ContinueOnError)
+	fset.StringVar(&opts.mapPath, "map", "soil-demo.ldtk", "LDtk project or Tiled map holding the levels")
+	fset.StringVar(&opts.level, "level", "0", "starting level, by identifier or index")
+	fset.IntVar(&opts.width, "width", 640, "window width")
+	fset.IntVar(&opts.height, "height", 480, "window height")
+	fset.BoolVar(&opts.fullscreen, "fullscreen", false, "start in fullscreen")
+	fset.UintVar(&opts.speed, "speed", 0, "initial speed step, 0 is the slowest")
+	fset.BoolVar(&opts.paused, "paused", false, "start with the simulation paused")
+	fset.StringVar(&opts.board, "board", "humidity", "initial board: humidity or soil")
+	fset.StringVar(&opts.rule, "rule", "humidity", "simulation rule: humidity, thermal to add the soil temperature, nutrients to carry the nutrients of the level or erosion to let water reshape the terrain")
+	fset.StringVar(&opts.erosion, "erosion", "", "JSON file overriding the transitions of the erosion rule")
+	fset.StringVar(&opts.themeDir, "theme", "", "directory with sprites overriding the embedded assets")
+	fset.BoolVar(&opts.watch, "watch", false, "reload the level file whenever it changes on disk")
+	fset.BoolVar(&opts.generate, "generate", false, "generate the levels from noise instead of reading the map file")
+	fset.Int64Var(&opts.seed, "seed", 0, "seed of the level generator, 0 picks one from the clock")
+	fset.StringVar(&opts.edges, "edges", "closed", "boundary of each edge of the board, like left=periodic,right=periodic,bottom=fixed:800")
+	fset.BoolVar(&opts.world, "world", false, "simulate all levels as one world, laid out by their world position")
+	fset.StringVar(&opts.compare, "compare", "", "comma separated levels shown next to the main one, by identifier or index, each optionally followed by :rule")
+	fset.StringVar(&opts.cmpEdges, "compare-edges", "", "boundary of the compared boards, defaults to -edges")
+	fset.StringVar(&opts.record, "record", "", "record every user action to this file")
+	fset.StringVar(&opts.replay, "replay", "", "play back a recording, its settings override the other flags")
+	fset.BoolVar(&opts.headless, "headless", false, "run the replay without a window and exit when it ends")
+	fset.StringVar(&opts.saveTo, "save-to", "", "file written by Ctrl+S, defaults to the map file itself")
+	fset.BoolVar(&opts.keepHum, "keep-humidity", false, "on reload, keep the humidity of cells whose material did not change")
+	if err := fset.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return opts, err
+	} else if err != nil {
+		return opts, errUsage
+	}
+
+	if opts.width <= 0 || opts.height <= 0 {
+		return opts, fmt.Errorf("invalid window size %dx%d", opts.width, opts.height)
+	}
+	if opts.board != "humidity" && opts.board != "soil" {
+		return opts, fmt.Errorf("unknown board %q, expected humidity or soil", opts.board)
+	}
+	if _, ok := rules[opts.rule]; !ok {
+		return opts, fmt.Errorf("unknown rule %q, expected humidity, thermal, nutrients or erosion", opts.rule)
+	}
+	// A replay brings its own rules
+	if opts.erosion != "" && opts.rule != "erosion" && opts.replay == "" {
+		return opts, errors.New("-erosion needs -rule erosion")
+	}
+	if opts.world && opts.rule != "humidity" {
+		return opts, errors.New("-world only runs the humidity rule")
+	}
+	if opts.generate && opts.watch {
+		return opts, errors.New("-watch needs a map file, it can't be used with -generate")
+	}
+	if opts.replay != "" && (opts.record != "" || opts.watch) {
+		return opts, errors.New("-replay can't be used with -record or -watch")
+	}
+	if opts.record != "" && opts.watch {
+		return opts, errors.New("-record can't be used with -watch, reloads are not recorded")
+	}
+	if opts.world && (opts.record != "" || opts.replay != "" || opts.watch) {
+		return opts, errors.New("-world can't be used with -record, -replay or -watch")
+	}
+	if opts.headless && opts.replay == "" {
+		return opts, errors.New("-headless needs a recording to -replay")
+	}
+	return opts, nil
+}
+
+// openLevels reads the levels of a map file through the source matching
+// its format.
+func openLevels(path string) (levels.LevelSource, []levels.Level, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("level file %q does not exist", path)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("level file %q: %w", path, err)
+	}
+
+	src, err := levels.NewSource(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	lvls, err := src.Levels()
+	if err != nil {
+		return nil, nil, fmt.Errorf("level file %q could not be loaded: %w", path, err)
+	}
+	if len(lvls) == 0 {
+		return nil, nil, fmt.Errorf("level file %q has no levels", path)
+	}
+	return src, lvls, nil
+}
+
+// setErosionRules replaces the default rules of -rule erosion with the ones
+// of a rules file, read from src.
+func setErosionRules(data []byte, src string) error {
+	er, err := boards.ParseErosionRules(bytes.NewReader(data), boards.DefaultErosionRules())
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	rules["erosion"] = internal.ErosionRule(er)
+	return nil
+}
+
+func findLevel(lvls []levels.Level, key string) (uint, error) {
+	for i, l := range lvls {
+		if l.Identifier == key {
+			return uint(i), nil
+		}
+	}
+	if i, err := strconv.Atoi(key); err == nil {
+		if i < 0 || i >= len(lvls) {
+			return 0, fmt.Errorf("level index %d out of range [0, %d)", i, len(lvls))
+		}
+		return uint(i), nil
+	}
+	return 0, fmt.Errorf("no level named %q", key)
+}
+
+func run(args []string) error {
+	opts, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+
+	var replay *internal.Replay
+	var erosion []byte
+	if opts.replay != "" {
+		if replay, err = internal.LoadReplay(opts.replay); err != nil {
+			return err
+		}
+		h := replay.Header
+		opts.mapPath, opts.generate, opts.seed = h.Map, h.Generate, h.Seed
+		opts.level, opts.speed, opts.paused = strconv.Itoa(int(h.Level)), h.Speed, h.Paused
+		opts.edges = h.Edges
+		// Recordings older than -rule ran the humidity rule
+		opts.rule = "humidity"
+		if h.Rule != "" {
+			opts.rule = h.Rule
+		}
+		if _, ok := rules[opts.rule]; !ok {
+			return fmt.Errorf("recording uses unknown rule %q", opts.rule)
+		}
+		if len(h.Erosion) > 0 {
+			erosion = h.Erosion
+			if err := setErosionRules(erosion, opts.replay); err != nil {
+				return err
+			}
+		}
+		opts.board = "humidity"
+		if h.Preview {
+			opts.board = "soil"
+		}
+	}
+
+	if opts.erosion != "" && replay == nil {
+		if erosion, err = os.ReadFile(opts.erosion); err != nil {
+			return err
+		}
+		if !json.Valid(erosion) {
+			return fmt.Errorf("%s: erosion rules are not valid JSON", opts.erosion)
+		}
+		if err := setErosionRules(erosion, opts.erosion); err != nil {
+			return err
+		}
+	}
+
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+	// Logged so the run can be repeated with -seed
+	log.Printf("Seed %d", opts.seed)
+
+	edges, err := boards.ParseBoundaries(opts.edges)
+	if err != nil {
+		return err
+	}
 
 	// Load Map
-	// Load the LDtk Project
-	ldtkProject, err := ldtkgo.Open("soil-demo.ldtk")
+	var src levels.LevelSource
+	var lvls []levels.Level
+	if opts.generate {
+		src = &levels.GeneratorSource{Seed: opts.seed, Count: 4, Width: 16, Height: 16}
+		lvls, err = src.Levels()
+	} else {
+		src, lvls, err = openLevels(opts.mapPath)
+	}
+	if err != nil {
+		return err
+	}
+	level, err := findLevel(lvls, opts.level)
 	if err != nil {
-		log.Fatalf("Map Loading Fail: %s", err)
+		return err
+	}
+
+	// Load Assets
+	conf, err := internal.NewConf(opts.themeDir)
+	var themeErr *internal.ThemeError
+	if errors.As(err, &themeErr) {
+		log.Printf("Using embedded assets: %s", err)
+	} else if err != nil {
+		return err
+	}
+	if tsSrc, ok := src.(levels.TilesetSource); ok {
+		if ts, ok := tsSrc.Tileset("Trees"); ok {
+			if err := conf.LoadTrees(ts); err != nil {
+				log.Printf("Tree Loading Fail: %s", err)
+			}
+		}
+	}
+
+	var watcher *internal.ProjectWatcher
+	if opts.watch {
+		watcher, err = internal.WatchProject(src, opts.mapPath, time.Second/2)
+		if err != nil {
+			return err
+		}
+		defer watcher.Close()
 	}
 
 	// Setup Simulation
+	start := internal.StartOptions{
+		Level:        level,
+		Speed:        opts.speed,
+		Paused:       opts.paused,
+		Preview:      opts.board == "soil",
+		Watcher:      watcher,
+		KeepHumidity: opts.keepHum,
+		ExportPath:   opts.saveTo,
+		Replay:       replay,
+		Edges:        edges,
+	}
+	if opts.compare != "" {
+		if opts.cmpEdges == "" {
+			opts.cmpEdges = opts.edges
+		}
+		cmpEdges, err := boards.ParseBoundaries(opts.cmpEdges)
+		if err != nil {
+			return err
+		}
+		for _, key := range strings.Split(opts.compare, ",") {
+			key, rule, hasRule := strings.Cut(strings.TrimSpace(key), ":")
+			i, err := findLevel(lvls, key)
+			if err != nil {
+				return err
+			}
+			c := internal.Comparison{Level: i, Edges: cmpEdges}
+			if hasRule {
+				if c.NewBoard = rules[rule]; c.NewBoard == nil {
+					return fmt.Errorf("compared level %s: unknown rule %q, expected humidity, thermal, nutrients or erosion", key, rule)
+				}
+			}
+			start.Compare = append(start.Compare, c)
+		}
+	}
+	if exp, ok := src.(levels.Exporter); ok {
+		start.Exporter = exp
+	}
+	if start.ExportPath == "" {
+		start.ExportPath = opts.mapPath
+	}
+	if opts.record != "" {
+		start.Recorder, err = internal.NewRecorder(opts.record, internal.ReplayHeader{
+			Map:      opts.mapPath,
+			Generate: opts.generate,
+			Seed:     opts.seed,
+			Level:    level,
+			Speed:    opts.speed,
+			Paused:   opts.paused,
+			Preview:  start.Preview,
+			Edges:    opts.edges,
+			Rule:     opts.rule,
+			Erosion:  erosion,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	state, err := internal.NewState(lvls, conf, start)
+	if err != nil {
+		return err
+	}
+	var scene stagehand.Scene[internal.State] = &internal.SimulationScene{
+		NewBoard: rules[opts.rule],
+		Preview:  &boards.EnumBoard{},
+	}
+	if opts.world {
+		world, err := boards.NewWorld(lvls, edges)
+		if err != nil {
+			return err
+		}
+		scene = &internal.WorldScene{World: world}
+	}
+	sm := stagehand.NewSceneManager[internal.State](scene, state)
+
+	if opts.headless {
+		for !replay.Done() {
+			if err := sm.Update(); err != nil {
+				return err
+			}
+		}
+		return replay.Err()
+	}
 
-	sm := stagehand.NewSceneManager[internal.State](&internal.SimulationScene{
-		Board:   &boards.HumidityBoard{},
-		Preview: &boards.EnumBoard{},
-	}, internal.State{
-		Levels: ldtkProject.Levels,
-		Config: internal.NewConf(),
-	})
+	ebiten.SetWindowSize(opts.width, opts.height)
+	ebiten.SetWindowTitle("Soil Demo")
+	ebiten.SetWindowResizable(true)
+	ebiten.SetFullscreen(opts.fullscreen)
 
-	if err := ebiten.RunGame(sm); err != nil {
-		log.Fatal(err)
+	err = ebiten.RunGame(sm)
+	if start.Recorder != nil {
+		if cerr := start.Recorder.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			os.Exit(0)
+		case errors.Is(err, errUsage):
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "soil-demo: %s\n", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,553 @@
+package util
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+)
+
+// Decoder for the Aseprite file format, as documented in
+// https://github.com/aseprite/aseprite/blob/main/docs/ase-file-specs.md
+// Only what the demo needs is supported: RGBA, grayscale and indexed sprites,
+// layers with normal blending and tags. Tilemaps are rejected.
+
+const (
+	aseFileMagic  = 0xA5E0
+	aseFrameMagic = 0xF1FA
+
+	aseChunkOldPalette = 0x0004
+	aseChunkLayer      = 0x2004
+	aseChunkCel        = 0x2005
+	aseChunkTags       = 0x2018
+	aseChunkPalette    = 0x2019
+
+	aseCelRaw        = 0
+	aseCelLinked     = 1
+	aseCelCompressed = 2
+
+	aseLayerVisible    = 1
+	aseLayerBackground = 8
+
+	aseFlagLayerOpacity = 1
+
+	aseFrameHeaderSize = 16
+	// Sprites and cels larger than this are rejected instead of allocated
+	aseMaxSide = 4096
+	// Aseprite palettes hold at most this many colors
+	aseMaxPalette = 1 << 16
+)
+
+type AsepriteLayer struct {
+	Name       string
+	Flags      uint16
+	Type       uint16
+	ChildLevel uint16
+	BlendMode  uint16
+	Opacity    uint8
+}
+
+func (l AsepriteLayer) Visible() bool {
+	return l.Flags&aseLayerVisible != 0
+}
+
+func (l AsepriteLayer) Background() bool {
+	return l.Flags&aseLayerBackground != 0
+}
+
+type AsepriteCel struct {
+	Layer   int
+	X, Y    int
+	Opacity uint8
+	Image   *image.NRGBA
+}
+
+type AsepriteFrame struct {
+	Duration time.Duration
+	Cels     []AsepriteCel
+}
+
+type AsepriteTag struct {
+	Name      string
+	From, To  int
+	Direction uint8
+}
+
+type Aseprite struct {
+	Width, Height    int
+	ColorDepth       int
+	Flags            uint32
+	TransparentIndex uint8
+	Palette          color.Palette
+	Layers           []AsepriteLayer
+	Frames           []AsepriteFrame
+	Tags             []AsepriteTag
+}
+
+type aseHeader struct {
+	FileSize         uint32
+	Magic            uint16
+	Frames           uint16
+	Width, Height    uint16
+	ColorDepth       uint16
+	Flags            uint32
+	Speed            uint16
+	_                [2]uint32
+	TransparentIndex uint8
+	_                [3]byte
+	Colors           uint16
+	_                [94]byte
+}
+
+type aseFrameHeader struct {
+	Size      uint32
+	Magic     uint16
+	OldChunks uint16
+	Duration  uint16
+	_         [2]byte
+	NewChunks uint32
+}
+
+func DecodeAseprite(r io.Reader) (*Aseprite, error) {
+	hdr := aseHeader{}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("aseprite: reading header: %w", err)
+	}
+	if hdr.Magic != aseFileMagic {
+		return nil, fmt.Errorf("aseprite: bad magic number %#x", hdr.Magic)
+	}
+	switch hdr.ColorDepth {
+	case 8, 16, 32:
+	default:
+		return nil, fmt.Errorf("aseprite: unsupported color depth %d", hdr.ColorDepth)
+	}
+	if hdr.Width == 0 || hdr.Height == 0 || hdr.Width > aseMaxSide || hdr.Height > aseMaxSide {
+		return nil, fmt.Errorf("aseprite: invalid size %dx%d", hdr.Width, hdr.Height)
+	}
+	if hdr.Frames == 0 {
+		return nil, fmt.Errorf("aseprite: no frames")
+	}
+
+	ase := &Aseprite{
+		Width:            int(hdr.Width),
+		Height:           int(hdr.Height),
+		ColorDepth:       int(hdr.ColorDepth),
+		Flags:            hdr.Flags,
+		TransparentIndex: hdr.TransparentIndex,
+	}
+
+	for i := 0; i < int(hdr.Frames); i++ {
+		fh := aseFrameHeader{}
+		if err := binary.Read(r, binary.LittleEndian, &fh); err != nil {
+			return nil, fmt.Errorf("aseprite: frame %d: %w", i, err)
+		}
+		if fh.Magic != aseFrameMagic {
+			return nil, fmt.Errorf("aseprite: frame %d: bad magic number %#x", i, fh.Magic)
+		}
+		if fh.Size < aseFrameHeaderSize {
+			return nil, fmt.Errorf("aseprite: frame %d: invalid size %d", i, fh.Size)
+		}
+		left := fh.Size - aseFrameHeaderSize
+		chunks := int(fh.NewChunks)
+		if chunks == 0 {
+			chunks = int(fh.OldChunks)
+		}
+
+		ase.Frames = append(ase.Frames, AsepriteFrame{Duration: time.Duration(fh.Duration) * time.Millisecond})
+		for c := 0; c < chunks; c++ {
+			if err := ase.readChunk(r, i, &left); err != nil {
+				return nil, fmt.Errorf("aseprite: frame %d: %w", i, err)
+			}
+		}
+		// Skip whatever the chunks leave of the frame, so the next one
+		// starts where the header says
+		if _, err := io.CopyN(io.Discard, r, int64(left)); err != nil {
+			return nil, fmt.Errorf("aseprite: frame %d: %w", i, err)
+		}
+	}
+
+	for _, t := range ase.Tags {
+		if t.From > t.To || t.To >= len(ase.Frames) {
+			return nil, fmt.Errorf("aseprite: tag %q: frames %d..%d out of range [0, %d)", t.Name, t.From, t.To, len(ase.Frames))
+		}
+	}
+	return ase, nil
+}
+
+// readChunk reads the next chunk of a frame, which has left bytes to go.
+func (ase *Aseprite) readChunk(r io.Reader, frame int, left *uint32) error {
+	var size uint32
+	var kind uint16
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &kind); err != nil {
+		return err
+	}
+	if size < 6 || size > *left {
+		return fmt.Errorf("chunk %#x: invalid size %d", kind, size)
+	}
+	*left -= size
+	data := make([]byte, size-6)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	cr := bytes.NewReader(data)
+
+	switch kind {
+	case aseChunkOldPalette:
+		// The new palette chunk takes precedence when both are present
+		if ase.Palette == nil {
+			return ase.readOldPalette(cr)
+		}
+	case aseChunkPalette:
+		return ase.readPalette(cr)
+	case aseChunkLayer:
+		return ase.readLayer(cr)
+	case aseChunkCel:
+		return ase.readCel(cr, frame)
+	case aseChunkTags:
+		return ase.readTags(cr)
+	}
+	return nil
+}
+
+func readAseString(r io.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func (ase *Aseprite) growPalette(size int) {
+	for len(ase.Palette) < size {
+		ase.Palette = append(ase.Palette, color.NRGBA{})
+	}
+}
+
+func (ase *Aseprite) readOldPalette(r io.Reader) error {
+	var packets uint16
+	if err := binary.Read(r, binary.LittleEndian, &packets); err != nil {
+		return err
+	}
+	idx := 0
+	for p := 0; p < int(packets); p++ {
+		var head [2]uint8 // skip, count
+		if err := binary.Read(r, binary.LittleEndian, &head); err != nil {
+			return err
+		}
+		idx += int(head[0])
+		count := int(head[1])
+		if count == 0 {
+			count = 256
+		}
+		if idx+count > aseMaxPalette {
+			return fmt.Errorf("palette over %d colors", aseMaxPalette)
+		}
+		ase.growPalette(idx + count)
+		for i := 0; i < count; i++ {
+			var rgb [3]uint8
+			if err := binary.Read(r, binary.LittleEndian, &rgb); err != nil {
+				return err
+			}
+			ase.Palette[idx] = color.NRGBA{rgb[0], rgb[1], rgb[2], 0xff}
+			idx++
+		}
+	}
+	return nil
+}
+
+func (ase *Aseprite) readPalette(r io.Reader) error {
+	var hdr struct {
+		Size, First, Last uint32
+		_                 [8]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+	if hdr.Size > aseMaxPalette {
+		return fmt.Errorf("palette of %d colors, over %d", hdr.Size, aseMaxPalette)
+	}
+	ase.Palette = nil
+	ase.growPalette(int(hdr.Size))
+	for i := hdr.First; i <= hdr.Last && int(i) < len(ase.Palette); i++ {
+		var entry struct {
+			Flags      uint16
+			R, G, B, A uint8
+		}
+		if err := binary.Read(r, binary.LittleEndian, &entry); err != nil {
+			return err
+		}
+		if entry.Flags&1 != 0 {
+			if _, err := readAseString(r); err != nil {
+				return err
+			}
+		}
+		ase.Palette[i] = color.NRGBA{entry.R, entry.G, entry.B, entry.A}
+	}
+	return nil
+}
+
+func (ase *Aseprite) readLayer(r io.Reader) error {
+	var hdr struct {
+		Flags, Type, ChildLevel uint16
+		_                       [2]uint16
+		BlendMode               uint16
+		Opacity                 uint8
+		_                       [3]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+	name, err := readAseString(r)
+	if err != nil {
+		return err
+	}
+	ase.Layers = append(ase.Layers, AsepriteLayer{
+		Name:       name,
+		Flags:      hdr.Flags,
+		Type:       hdr.Type,
+		ChildLevel: hdr.ChildLevel,
+		BlendMode:  hdr.BlendMode,
+		Opacity:    hdr.Opacity,
+	})
+	return nil
+}
+
+func (ase *Aseprite) readTags(r io.Reader) error {
+	var count uint16
+	var reserved [8]byte
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &reserved); err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		var hdr struct {
+			From, To  uint16
+			Direction uint8
+			Repeat    uint16
+			_         [6]byte
+			Color     [3]uint8
+			_         uint8
+		}
+		if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+			return err
+		}
+		name, err := readAseString(r)
+		if err != nil {
+			return err
+		}
+		ase.Tags = append(ase.Tags, AsepriteTag{
+			Name:      name,
+			From:      int(hdr.From),
+			To:        int(hdr.To),
+			Direction: hdr.Direction,
+		})
+	}
+	return nil
+}
+
+func (ase *Aseprite) readCel(r io.Reader, frame int) error {
+	var hdr struct {
+		Layer   uint16
+		X, Y    int16
+		Opacity uint8
+		Type    uint16
+		ZIndex  int16
+		_       [5]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+	if int(hdr.Layer) >= len(ase.Layers) {
+		return fmt.Errorf("cel of layer %d, there are %d", hdr.Layer, len(ase.Layers))
+	}
+	cel := AsepriteCel{
+		Layer:   int(hdr.Layer),
+		X:       int(hdr.X),
+		Y:       int(hdr.Y),
+		Opacity: hdr.Opacity,
+	}
+
+	switch hdr.Type {
+	case aseCelLinked:
+		var src uint16
+		if err := binary.Read(r, binary.LittleEndian, &src); err != nil {
+			return err
+		}
+		if int(src) >= frame {
+			return fmt.Errorf("cel linked to frame %d", src)
+		}
+		for _, c := range ase.Frames[src].Cels {
+			if c.Layer == cel.Layer {
+				cel.Image = c.Image
+			}
+		}
+	case aseCelRaw, aseCelCompressed:
+		var size [2]uint16
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return err
+		}
+		if size[0] > aseMaxSide || size[1] > aseMaxSide {
+			return fmt.Errorf("invalid cel size %dx%d", size[0], size[1])
+		}
+		if hdr.Type == aseCelCompressed {
+			zr, err := zlib.NewReader(r)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+			r = zr
+		}
+		img, err := ase.readPixels(r, int(size[0]), int(size[1]), cel.Layer)
+		if err != nil {
+			return err
+		}
+		cel.Image = img
+	default:
+		return fmt.Errorf("unsupported cel type %d", hdr.Type)
+	}
+
+	ase.Frames[frame].Cels = append(ase.Frames[frame].Cels, cel)
+	return nil
+}
+
+func (ase *Aseprite) readPixels(r io.Reader, w, h, layer int) (*image.NRGBA, error) {
+	buf := make([]byte, w*h*ase.ColorDepth/8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	background := layer < len(ase.Layers) && ase.Layers[layer].Background()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < w*h; i++ {
+		var clr color.NRGBA
+		switch ase.ColorDepth {
+		case 32:
+			clr = color.NRGBA{buf[i*4], buf[i*4+1], buf[i*4+2], buf[i*4+3]}
+		case 16:
+			clr = color.NRGBA{buf[i*2], buf[i*2], buf[i*2], buf[i*2+1]}
+		case 8:
+			idx := buf[i]
+			if (idx != ase.TransparentIndex || background) && int(idx) < len(ase.Palette) {
+				clr = ase.Palette[idx].(color.NRGBA)
+			}
+		}
+		img.SetNRGBA(i%w, i/w, clr)
+	}
+	return img, nil
+}
+
+// LayerVisible reports if the layer and every group containing it are visible.
+func (ase *Aseprite) LayerVisible(layer int) bool {
+	level := ase.Layers[layer].ChildLevel
+	for i := layer; i >= 0; i-- {
+		l := ase.Layers[i]
+		if i == layer || l.ChildLevel < level {
+			if !l.Visible() {
+				return false
+			}
+			level = l.ChildLevel
+		}
+		if level == 0 {
+			break
+		}
+	}
+	return true
+}
+
+func (ase *Aseprite) drawCel(dst draw.Image, cel AsepriteCel) {
+	if cel.Image == nil {
+		return
+	}
+	opacity := int(cel.Opacity)
+	if ase.Flags&aseFlagLayerOpacity != 0 {
+		opacity = opacity * int(ase.Layers[cel.Layer].Opacity) / 255
+	}
+	r := cel.Image.Bounds().Add(image.Pt(cel.X, cel.Y))
+	draw.DrawMask(dst, r, cel.Image, image.Point{}, image.NewUniform(color.Alpha{uint8(opacity)}), image.Point{}, draw.Over)
+}
+
+// LayerImage renders a single layer of a frame, regardless of its visibility.
+func (ase *Aseprite) LayerImage(frame, layer int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, ase.Width, ase.Height))
+	for _, cel := range ase.Frames[frame].Cels {
+		if cel.Layer == layer {
+			ase.drawCel(img, cel)
+		}
+	}
+	return img
+}
+
+// FrameImage flattens all visible layers of a frame, bottom to top.
+func (ase *Aseprite) FrameImage(frame int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, ase.Width, ase.Height))
+	for layer := range ase.Layers {
+		if !ase.LayerVisible(layer) {
+			continue
+		}
+		for _, cel := range ase.Frames[frame].Cels {
+			if cel.Layer == layer {
+				ase.drawCel(img, cel)
+			}
+		}
+	}
+	return img
+}
+
+func (ase *Aseprite) Tag(name string) (AsepriteTag, bool) {
+	for _, t := range ase.Tags {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return AsepriteTag{}, false
+}
+
+func hStrip(imgs []*image.NRGBA) *image.NRGBA {
+	if len(imgs) == 0 {
+		return image.NewNRGBA(image.Rect(0, 0, 0, 0))
+	}
+	w, h := imgs[0].Bounds().Dx(), imgs[0].Bounds().Dy()
+	strip := image.NewNRGBA(image.Rect(0, 0, w*len(imgs), h))
+	for i, img := range imgs {
+		draw.Draw(strip, image.Rect(i*w, 0, (i+1)*w, h), img, image.Point{}, draw.Src)
+	}
+	return strip
+}
+
+// FrameStrip lays the flattened frames from..to (inclusive) side by side.
+func (ase *Aseprite) FrameStrip(from, to int) (*image.NRGBA, error) {
+	if from < 0 || from > to || to >= len(ase.Frames) {
+		return nil, fmt.Errorf("aseprite: frames %d..%d out of range [0, %d)", from, to, len(ase.Frames))
+	}
+	imgs := []*image.NRGBA{}
+	for i := from; i <= to; i++ {
+		imgs = append(imgs, ase.FrameImage(i))
+	}
+	return hStrip(imgs), nil
+}
+
+// LayerStrip lays every layer of a frame side by side, bottom layer first.
+func (ase *Aseprite) LayerStrip(frame int) (*image.NRGBA, error) {
+	if frame < 0 || frame >= len(ase.Frames) {
+		return nil, fmt.Errorf("aseprite: frame %d out of range [0, %d)", frame, len(ase.Frames))
+	}
+	imgs := []*image.NRGBA{}
+	for i, l := range ase.Layers {
+		if l.Type != 1 { // Groups have no pixels of their own
+			imgs = append(imgs, ase.LayerImage(frame, i))
+		}
+	}
+	return hStrip(imgs), nil
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const assetsDir = "../internal/assets"
+
+func decodeAseFile(t *testing.T, name string) *Aseprite {
+	t.Helper()
+	f, err := os.Open(filepath.Join(assetsDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ase, err := DecodeAseprite(f)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	return ase
+}
+
+func decodePNGFile(t *testing.T, name string) *image.NRGBA {
+	t.Helper()
+	f, err := os.Open(filepath.Join(assetsDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	out := image.NewNRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	return out
+}
+
+// The embedded buttons are kept as both Aseprite sources and PNG exports;
+// decoding the sources must give the exports back.
+func TestDecodeEmbeddedButtons(t *testing.T) {
+	names, err := filepath.Glob(filepath.Join(assetsDir, "*.aseprite"))
+	if err != nil || len(names) == 0 {
+		t.Fatalf("no Aseprite assets found: %v", err)
+	}
+	for _, path := range names {
+		name := filepath.Base(path)
+		ase := decodeAseFile(t, name)
+		want := decodePNGFile(t, strings.TrimSuffix(name, ".aseprite")+".png")
+
+		// The speed button has one layer per speed, the others one frame each
+		strip, err := ase.FrameStrip(0, len(ase.Frames)-1)
+		if name == "speed-btn.aseprite" {
+			strip, err = ase.LayerStrip(0)
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if strip.Bounds() != want.Bounds() {
+			t.Errorf("%s: decoded %v, want %v", name, strip.Bounds(), want.Bounds())
+			continue
+		}
+		for y := 0; y < want.Bounds().Dy(); y++ {
+			for x := 0; x < want.Bounds().Dx(); x++ {
+				got, exp := strip.NRGBAAt(x, y), want.NRGBAAt(x, y)
+				// Fully transparent pixels may keep any colour
+				if got != exp && (got.A != 0 || exp.A != 0) {
+					t.Fatalf("%s: pixel (%d, %d) is %v, want %v", name, x, y, got, exp)
+				}
+			}
+		}
+	}
+}
+
+// Bytes past the last chunk of a frame are skipped, not read as the next
+// frame.
+func TestDecodeAsepriteFramePadding(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join(assetsDir, "play-btn.aseprite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The frame padded, then again as it is
+	const pad = 10
+	size := binary.LittleEndian.Uint32(src[128:])
+	frame := src[128 : 128+size]
+	file := append([]byte{}, src[:128]...)
+	file = append(file, frame...)
+	file = append(file, make([]byte, pad)...)
+	file = append(file, frame...)
+	binary.LittleEndian.PutUint32(file, uint32(len(file)))
+	binary.LittleEndian.PutUint16(file[6:], 2)
+	binary.LittleEndian.PutUint32(file[128:], size+pad)
+
+	ase, err := DecodeAseprite(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ase.Frames) != 2 || !reflect.DeepEqual(ase.Frames[0], ase.Frames[1]) {
+		t.Error("the frame after the padding doesn't match the first one")
+	}
+}
+
+func TestDecodeAsepriteErrors(t *testing.T) {
+	src, err := os.ReadFile(filepath.Join(assetsDir, "play-btn.aseprite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// patch returns a copy of the file with a little endian value written
+	// at off
+	patch := func(off int, v any) []byte {
+		buf := bytes.Buffer{}
+		binary.Write(&buf, binary.LittleEndian, v)
+		out := append([]byte{}, src...)
+		copy(out[off:], buf.Bytes())
+		return out
+	}
+	for _, c := range []struct {
+		name string
+		file []byte
+	}{
+		{"truncated", src[:len(src)/2]},
+		{"no frames", patch(6, uint16(0))},
+		{"zero width", patch(8, uint16(0))},
+		{"huge size", patch(8, [2]uint16{0xffff, 0xffff})},
+		{"frame size", patch(128, uint32(4))},
+		{"chunk over the frame", patch(128+16, uint32(0xffffffff))},
+	} {
+		if _, err := DecodeAseprite(bytes.NewReader(c.file)); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+
+	ase, err := DecodeAseprite(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ase.FrameStrip(0, len(ase.Frames)); err == nil {
+		t.Error("a strip past the last frame should fail")
+	}
+	if _, err := ase.FrameStrip(1, 0); err == nil {
+		t.Error("a reversed strip should fail")
+	}
+	if _, err := ase.LayerStrip(-1); err == nil {
+		t.Error("a negative frame should fail")
+	}
+}
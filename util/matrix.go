@@ -76,13 +76,15 @@ func MakeMatrix[V any](size int, value V) [][]V {
 	return m
 }
 
-func MakeRandMatrixUint8(size, maxValue int) [][]uint8 {
+// MakeRandMatrixUint8 fills a matrix with values in [1, maxValue] drawn
+// from rng, so the same seed yields the same matrix.
+func MakeRandMatrixUint8(rng *rand.Rand, size, maxValue int) [][]uint8 {
 	m := [][]uint8{}
 
 	for x := 0; x < size; x++ {
 		row := []uint8{}
 		for y := 0; y < size; y++ {
-			row = append(row, uint8(rand.Intn(maxValue)+1))
+			row = append(row, uint8(rng.Intn(maxValue)+1))
 		}
 		m = append(m, row)
 	}
@@ -104,13 +106,14 @@ func MakeMatrixBool(size int) [][]bool {
 	return m
 }
 
-func MakeRandMatrixBool(size, threshold int) [][]bool {
+// MakeRandMatrixBool sets each cell with a chance of threshold percent.
+func MakeRandMatrixBool(rng *rand.Rand, size, threshold int) [][]bool {
 	m := [][]bool{}
 
 	for x := 0; x < size; x++ {
 		row := []bool{}
 		for y := 0; y < size; y++ {
-			row = append(row, rand.Intn(101) < threshold)
+			row = append(row, rng.Intn(101) < threshold)
 		}
 		m = append(m, row)
 	}
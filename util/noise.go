@@ -0,0 +1,80 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Noise is a seeded 2D Perlin gradient noise. The same seed always yields
+// the same field.
+type Noise struct {
+	perm [512]int
+}
+
+func NewNoise(rng *rand.Rand) *Noise {
+	n := &Noise{}
+	p := rng.Perm(256)
+	for i := range n.perm {
+		n.perm[i] = p[i%256]
+	}
+	return n
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash int, x, y float64) float64 {
+	switch hash & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x
+	case 5:
+		return -x
+	case 6:
+		return y
+	}
+	return -y
+}
+
+// At returns the noise at (x, y), roughly within [-1, 1].
+func (n *Noise) At(x, y float64) float64 {
+	fx, fy := math.Floor(x), math.Floor(y)
+	xi, yi := int(fx)&255, int(fy)&255
+	x, y = x-fx, y-fy
+	u, v := fade(x), fade(y)
+
+	aa := n.perm[n.perm[xi]+yi]
+	ab := n.perm[n.perm[xi]+yi+1]
+	ba := n.perm[n.perm[xi+1]+yi]
+	bb := n.perm[n.perm[xi+1]+yi+1]
+
+	return lerp(
+		lerp(grad(aa, x, y), grad(ba, x-1, y), u),
+		lerp(grad(ab, x, y-1), grad(bb, x-1, y-1), u),
+		v,
+	)
+}
+
+// Fractal sums octaves of noise, each with double the frequency and half
+// the amplitude of the previous one. The result is normalised to [-1, 1].
+func (n *Noise) Fractal(x, y float64, octaves int) float64 {
+	sum, amp, norm := 0., 1., 0.
+	for i := 0; i < octaves; i++ {
+		sum += n.At(x, y) * amp
+		norm += amp
+		x, y, amp = x*2, y*2, amp/2
+	}
+	return sum / norm
+}
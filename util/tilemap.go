@@ -24,7 +24,7 @@ func (tm *TileMap) GetTile(index uint) image.Image {
 
 	r := image.Rect(int(sx), int(sy), int(sx+tm.TXSize()), int(sy+tm.TYSize()))
 	t := image.NewRGBA(r)
-	draw.Draw(t, r, tm.src, image.Point{}, draw.Src)
+	draw.Draw(t, r, tm.src, r.Min, draw.Src)
 	return t
 }
 